@@ -3,21 +3,45 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
+	intitools "github.com/0xJeti/intitools/pkg/intigo"
 	"github.com/namsral/flag"
 )
 
 const defaultTick = 60 * time.Second
 
 type config struct {
-	tick        time.Duration
-	username    string
-	password    string
-	secret      string
-	webhookurl  string
-	webhooktype string
-	sendlast    int
+	tick         time.Duration
+	tickmin      time.Duration
+	tickmax      time.Duration
+	jitter       float64
+	offhours     string
+	username     string
+	password     string
+	secret       string
+	webhookurl   string
+	webhooktype  string
+	sendlast     int
+	unknownlog   string
+	attachraw    bool
+	filter       string
+	sinks        string
+	record       string
+	enrich       bool
+	archive      string
+	ledger       string
+	snapshots    string
+	programttl   time.Duration
+	diffmode     string
+	catalog      string
+	catalogtick  time.Duration
+	metadata     bool
+	metadatatick time.Duration
+	follow       []string
+	location     *time.Location
+	timeformat   string
 }
 
 func (c *config) init(args []string) error {
@@ -25,20 +49,42 @@ func (c *config) init(args []string) error {
 	flags.String(flag.DefaultConfigFlagname, "", "Path to config file")
 
 	var (
-		tick        = flags.Duration("tick", defaultTick, "Ticking interval")
-		username    = flags.String("username", "", "Intigriti username (e-mail)")
-		password    = flags.String("password", "", "Intigriti password")
-		secret      = flags.String("secret", "", "Intigriti 2FA secret")
-		webhookurl  = flags.String("webhook", "", "Webhook URL")
-		webhooktype = flags.String("type", "slack", "Webhook type [slack|discord]")
-		sendlast    = flags.Int("last", 0, "Number of activity entries sent on start (for debugging)")
+		tick         = flags.Duration("tick", defaultTick, "Ticking interval")
+		tickmin      = flags.Duration("tick-min", 0, "Ticking interval after new activity (default tick/2)")
+		tickmax      = flags.Duration("tick-max", 0, "Maximum ticking interval when idle, in off-hours or on errors (default 5*tick)")
+		jitter       = flags.Float64("jitter", 0.1, "Random jitter as a fraction of the ticking interval (negative disables)")
+		offhours     = flags.String("off-hours", "", "Daily ranges polled with maximum ticking interval, e.g. 22:00-07:00")
+		username     = flags.String("username", "", "Intigriti username (e-mail)")
+		password     = flags.String("password", "", "Intigriti password")
+		secret       = flags.String("secret", "", "Intigriti 2FA secret")
+		webhookurl   = flags.String("webhook", "", "Webhook URL")
+		webhooktype  = flags.String("type", "slack", "Webhook type [slack|discord]")
+		sendlast     = flags.Int("last", 0, "Number of activity entries sent on start (for debugging)")
+		unknownlog   = flags.String("unknown-log", "", "File for logging unrecognized activities, e.g. unrecognized-activities.log")
+		attachraw    = flags.Bool("attach-unknown", false, "Attach raw payload of unrecognized activities to notifications")
+		filter       = flags.String("filter", "", "Path to filter rules for the webhook")
+		sinks        = flags.String("sinks", "", "Path to JSON file with additional sinks")
+		record       = flags.String("record", "", "File for recording all received activities")
+		archive      = flags.String("archive", "", "Path to local activity archive directory")
+		ledger       = flags.String("ledger", "", "Path to payout ledger")
+		snapshots    = flags.String("snapshots", "", "Path to program snapshots directory")
+		catalog      = flags.String("catalog", "", "File with known programs; enables notifications on new programs and invitations")
+		catalogtick  = flags.Duration("catalog-tick", time.Hour, "Interval of checking for new programs and invitations")
+		metadata     = flags.Bool("metadata", false, "Notify about program changes without activities (bounties, confidentiality level, identity check...)")
+		metadatatick = flags.Duration("metadata-tick", 6*time.Hour, "Interval of checking followed programs for changes without activities")
+		follow       = flags.String("follow", "", "Programs checked by -metadata (comma separated COMPANY/HANDLE, default all joined programs)")
+		programttl   = flags.Duration("program-ttl", intitools.DefaultProgramTTL, "How long fetched program details are reused (negative disables caching)")
+		diffmode     = flags.String("diff-mode", intitools.DefaultDiffMode, "Diff mode of program texts ["+strings.Join(intitools.DiffModes, "|")+"]")
+		timezone     = flags.String("timezone", "", "Display time zone, e.g. Europe/Warsaw (default local)")
+		timeformat   = flags.String("timeformat", intitools.DefaultTimeFormat, "Display time format (Go layout)")
+		enrich       = flags.Bool("enrich", true, "Fetch submission details and message text for submission activities")
 	)
 
 	if err := flags.Parse(args[1:]); err != nil {
 		return err
 	}
 
-	if *username == "" || *password == "" || *webhookurl == "" {
+	if *username == "" || *password == "" || (*webhookurl == "" && *sinks == "") {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
 		flags.PrintDefaults()
 		os.Exit(1)
@@ -46,11 +92,44 @@ func (c *config) init(args []string) error {
 
 	c.username = *username
 	c.tick = *tick
+	c.tickmin = *tickmin
+	c.tickmax = *tickmax
+	c.jitter = *jitter
+	c.offhours = *offhours
 	c.password = *password
 	c.secret = *secret
 	c.webhookurl = *webhookurl
 	c.webhooktype = *webhooktype
 	c.sendlast = *sendlast
+	c.unknownlog = *unknownlog
+	c.attachraw = *attachraw
+	c.filter = *filter
+	c.sinks = *sinks
+	c.record = *record
+	c.enrich = *enrich
+	c.archive = *archive
+	c.ledger = *ledger
+	c.snapshots = *snapshots
+	c.programttl = *programttl
+	c.diffmode = *diffmode
+	if !intitools.ValidDiffMode(c.diffmode) {
+		return fmt.Errorf("unknown diff mode %q", c.diffmode)
+	}
+	c.catalog = *catalog
+	c.catalogtick = *catalogtick
+	c.metadata = *metadata
+	c.metadatatick = *metadatatick
+	c.follow = splitList(*follow)
+	c.timeformat = *timeformat
+
+	c.location = time.Local
+	if *timezone != "" {
+		loc, err := time.LoadLocation(*timezone)
+		if err != nil {
+			return fmt.Errorf("invalid time zone %q: %w", *timezone, err)
+		}
+		c.location = loc
+	}
 
 	return nil
 }
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	intitools "github.com/0xJeti/intitools/pkg/intigo"
+	"github.com/namsral/flag"
+)
+
+// filterCommand evaluates filter rules against recorded activities:
+//
+//	inti-activity filter -rules rules.json activities.log [...]
+//	inti-activity filter -sinks sinks.json activities.log [...]
+//
+// Rules with asset kinds match diffs of domain updates, which are computed
+// from snapshots (-snapshots) or fetched programs (-username, -password).
+func filterCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet(args[0], flag.ExitOnError)
+
+	var (
+		rules  = flags.String("rules", "", "Path to filter rules")
+		sinks  = flags.String("sinks", "", "Path to JSON file with sinks (tests filter of every sink)")
+		source = newProgramSource(flags)
+	)
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if (*rules == "") == (*sinks == "") || flags.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Usage of %s filter: [-rules FILE | -sinks FILE] [-snapshots DIR | -username USER -password PASS] ACTIVITIES...\n", os.Args[0])
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+
+	var targets []*sink
+	if *rules != "" {
+		f, err := intitools.LoadFilter(*rules)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, &sink{Name: "rules", Filter: f})
+	} else {
+		var err error
+		if targets, err = readSinks(*sinks); err != nil {
+			return err
+		}
+	}
+
+	withDiffs := *source.snapshots != "" || *source.username != ""
+	if withDiffs {
+		if err := source.open(); err != nil {
+			return err
+		}
+	}
+
+	var events []intitools.ActivityEvent
+	for _, path := range flags.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		list, err := intitools.ReadActivities(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", path, err)
+		}
+		for _, a := range list {
+			e := intitools.ActivityEvent{Activity: a}
+			if withDiffs {
+				e.Diff = activityDomainsDiff(source, a)
+			}
+			events = append(events, e)
+		}
+	}
+
+	for _, t := range targets {
+		allowed := 0
+		fmt.Fprintf(out, "== %s\n", t.Name)
+		for _, e := range events {
+			a := e.Activity
+			action := intitools.FilterExclude
+			if t.Filter.AllowEvent(e) {
+				action = intitools.FilterInclude
+				allowed++
+			}
+
+			rule := "default"
+			if r := t.Filter.MatchEvent(e); r != nil {
+				rule = r.Name
+				if rule == "" {
+					rule = "unnamed rule"
+				}
+			}
+
+			title := a.Submissiontitle
+			if title == "" {
+				title = a.Title
+			}
+			fmt.Fprintf(out, "%-7s %3d %-20s %-40s (%s)\n", action, a.Discriminator, a.Programhandle, title, rule)
+		}
+		fmt.Fprintf(out, "%d of %d activities included\n", allowed, len(events))
+	}
+
+	return nil
+}
+
+// activityDomainsDiff returns the diff of a domains update (nil for other
+// activities or when the program is not available)
+func activityDomainsDiff(source *programSource, a intitools.Activity) *intitools.ProgramDiff {
+	if a.Discriminator != 27 {
+		return nil
+	}
+
+	p, err := source.program(context.Background(), a.Companyhandle, a.Programhandle)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot get program %s/%s: %s\n", a.Companyhandle, a.Programhandle, err)
+		return nil
+	}
+
+	return intitools.ProgramDomainsDiff(p, a, intitools.DefaultDiffMode)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+
+	intitools "github.com/0xJeti/intitools/pkg/intigo"
+	"github.com/namsral/flag"
+)
+
+// ledgerCommand prints payout totals or exports the ledger as CSV:
+//
+//	inti-activity ledger -ledger FILE [-by month|program|currency] [-rates FILE -currency EUR]
+//	inti-activity ledger -ledger FILE -csv > payouts.csv
+//	inti-activity ledger -ledger FILE -import activities.log
+func ledgerCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet(args[0], flag.ExitOnError)
+
+	var (
+		path     = flags.String("ledger", "", "Path to payout ledger")
+		by       = flags.String("by", "month", "Group totals by [month|program|currency]")
+		since    = flags.String("since", "", "Start date (YYYY-MM-DD or age, e.g. 90d)")
+		until    = flags.String("until", "", "End date, exclusive (YYYY-MM-DD or age, e.g. 30d)")
+		asCSV    = flags.Bool("csv", false, "Export payouts as CSV")
+		ratesF   = flags.String("rates", "", "Path to conversion rates file (lines of CURRENCY RATE)")
+		currency = flags.String("currency", "EUR", "Currency of the conversion rates")
+		imports  = flags.String("import", "", "Add payouts from activity log (e.g. written with -record)")
+	)
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if *path == "" {
+		fmt.Fprintf(os.Stderr, "Usage of %s ledger: -ledger FILE [options]\n", os.Args[0])
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+
+	l, err := intitools.OpenLedger(*path)
+	if err != nil {
+		return err
+	}
+
+	if *imports != "" {
+		if err := importPayouts(l, *imports); err != nil {
+			return err
+		}
+	}
+
+	from, err := parseDate(*since)
+	if err != nil {
+		return err
+	}
+	to, err := parseDate(*until)
+	if err != nil {
+		return err
+	}
+
+	all, err := l.Entries()
+	if err != nil {
+		return err
+	}
+
+	var entries []intitools.LedgerEntry
+	for _, e := range all {
+		if !from.IsZero() && e.PaidAt.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !e.PaidAt.Before(to) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	var rates intitools.Rates
+	if *ratesF != "" {
+		if rates, err = intitools.LoadRates(*ratesF); err != nil {
+			return err
+		}
+	}
+
+	if *asCSV {
+		return writeLedgerCSV(out, entries, rates, *currency)
+	}
+
+	totals, err := intitools.LedgerTotals(entries, *by, nil, rates, *currency)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range totals {
+		fmt.Fprintf(out, "%-30s %5d  %s\n", t.Group, t.Count, intitools.FormatMoney(t.Currency, float64(t.Cents)/100))
+	}
+
+	if *by == "currency" {
+		return nil
+	}
+
+	// Grand total per currency (a single one with -rates)
+	grand, err := intitools.LedgerTotals(entries, "currency", nil, rates, *currency)
+	if err != nil {
+		return err
+	}
+	for _, t := range grand {
+		fmt.Fprintf(out, "%-30s %5d  %s\n", "TOTAL", t.Count, intitools.FormatMoney(t.Currency, float64(t.Cents)/100))
+	}
+
+	return nil
+}
+
+func importPayouts(l *intitools.Ledger, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	activities, err := intitools.ReadActivities(f)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", path, err)
+	}
+
+	imported := 0
+	for _, a := range activities {
+		if a.Discriminator != 5 {
+			continue
+		}
+		entry, err := intitools.NewLedgerEntry(a)
+		if err != nil {
+			return err
+		}
+		if err := l.Add(entry); err != nil {
+			return err
+		}
+		imported++
+	}
+
+	fmt.Fprintf(os.Stderr, "%d payouts found in %s\n", imported, path)
+
+	return nil
+}
+
+func writeLedgerCSV(out io.Writer, entries []intitools.LedgerEntry, rates intitools.Rates, currency string) error {
+	w := csv.NewWriter(out)
+
+	header := []string{"date", "submission", "title", "program", "company", "currency", "amount"}
+	if rates != nil {
+		header = append(header, "amount_"+currency)
+	}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		row := []string{
+			e.PaidAt.Format("2006-01-02"),
+			e.SubmissionCode,
+			e.SubmissionTitle,
+			e.ProgramName,
+			e.CompanyHandle,
+			e.Currency,
+			e.Amount(),
+		}
+		if rates != nil {
+			cents, err := rates.Convert(e.Currency, e.Cents, currency)
+			if err != nil {
+				return err
+			}
+			row = append(row, intitools.FormatCents(cents))
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	intitools "github.com/0xJeti/intitools/pkg/intigo"
+)
+
+func TestWriteLedgerCSV(t *testing.T) {
+	entries := []intitools.LedgerEntry{
+		{PaidAt: time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC), SubmissionCode: "ACME-1", SubmissionTitle: "XSS, stored", ProgramName: "Acme", CompanyHandle: "acme", Currency: "EUR", Cents: 10000},
+		{PaidAt: time.Date(2024, 2, 5, 12, 0, 0, 0, time.UTC), SubmissionCode: "ACME-2", SubmissionTitle: "IDOR", ProgramName: "Acme", CompanyHandle: "acme", Currency: "USD", Cents: 5050},
+	}
+
+	tests := []struct {
+		name  string
+		rates intitools.Rates
+		want  string
+		err   bool
+	}{
+		{"no rates", nil, "date,submission,title,program,company,currency,amount\n" +
+			"2024-01-10,ACME-1,\"XSS, stored\",Acme,acme,EUR,100.00\n" +
+			"2024-02-05,ACME-2,IDOR,Acme,acme,USD,50.50\n", false},
+		{"converted", intitools.Rates{"USD": 0.9}, "date,submission,title,program,company,currency,amount,amount_EUR\n" +
+			"2024-01-10,ACME-1,\"XSS, stored\",Acme,acme,EUR,100.00,100.00\n" +
+			"2024-02-05,ACME-2,IDOR,Acme,acme,USD,50.50,45.45\n", false},
+		{"missing rate", intitools.Rates{"GBP": 1.15}, "", true},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		err := writeLedgerCSV(&out, entries, tt.rates, "EUR")
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error = %v, want error %t", tt.name, err, tt.err)
+			continue
+		}
+		if !tt.err && out.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, out.String(), tt.want)
+		}
+	}
+}
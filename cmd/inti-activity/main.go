@@ -14,7 +14,26 @@ import (
 	"golang.org/x/time/rate"
 )
 
+// commands maps subcommand names to their implementations
+var commands = map[string]func(args []string, out io.Writer) error{
+	"filter":   filterCommand,
+	"search":   searchCommand,
+	"ledger":   ledgerCommand,
+	"snapshot": snapshotCommand,
+	"scope":    scopeCommand,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[1:], os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	ctx := context.Background()
 	ctx, cancel := context.WithCancel(ctx)
 
@@ -28,20 +47,19 @@ func main() {
 		cancel()
 	}()
 
+	// The first signal stops the pipeline and waits for running hooks, the
+	// second one exits immediately
 	go func() {
-		for {
-			select {
-			case s := <-signalChan:
-				switch s {
-				case syscall.SIGHUP:
-					conf.init(os.Args)
-				case os.Interrupt:
-					cancel()
+		for s := range signalChan {
+			switch s {
+			case syscall.SIGHUP:
+				conf.init(os.Args)
+			case os.Interrupt, syscall.SIGTERM:
+				if ctx.Err() != nil {
 					os.Exit(1)
 				}
-			case <-ctx.Done():
-				log.Printf("Done.")
-				os.Exit(1)
+				log.Printf("Shutting down...")
+				cancel()
 			}
 		}
 	}()
@@ -50,85 +68,118 @@ func main() {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
 	}
+	log.Printf("Done.")
 }
 
 func run(ctx context.Context, conf *config, out io.Writer) error {
-	conf.init(os.Args)
+	if err := conf.init(os.Args); err != nil {
+		return err
+	}
 
 	rl := rate.NewLimiter(rate.Every(time.Second), 2) // 2 requests every second
 	c := intitools.NewClient(conf.username, conf.password, conf.secret, rl)
 	c.WebhookURL = conf.webhookurl
+	c.UnknownLog = conf.unknownlog
+	c.AttachUnknown = conf.attachraw
+	c.Location = conf.location
+	c.TimeFormat = conf.timeformat
+	c.ProgramTTL = conf.programttl
+	c.DiffMode = conf.diffmode
+	if conf.snapshots != "" {
+		store, err := intitools.OpenSnapshotStore(conf.snapshots)
+		if err != nil {
+			return err
+		}
+		c.Snapshots = store
+	}
 
-	sendlast := conf.sendlast
+	sinks, err := loadSinks(conf)
+	if err != nil {
+		return err
+	}
+
+	offhours, err := intitools.ParseTimeRanges(conf.offhours)
+	if err != nil {
+		return err
+	}
+	scheduler := &intitools.Scheduler{
+		Interval:    conf.tick,
+		MinInterval: conf.tickmin,
+		MaxInterval: conf.tickmax,
+		Jitter:      conf.jitter,
+		OffHours:    offhours,
+	}
 
 	log.SetOutput(os.Stdout)
 
 	log.Printf("Starting monitoring with tick %s", conf.tick)
 	httpctx := context.Background()
 	c.HttpCtx = httpctx
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-
-		case <-time.Tick(conf.tick):
-			err := c.Authenticate()
-			if err != nil {
-				log.Printf("Authentication error: %s\n", err)
-				continue
-
-			}
-
-			numActivities, err := c.CheckActivity()
-			if err != nil {
-				log.Printf("CheckActivity error: %s\n", err)
-				continue
-			}
-
-			// Use sendlast for first iteration and reset for all other
-			numActivities += sendlast
-			sendlast = 0
 
-			if numActivities == 0 {
-				continue
-			}
-
-			res, err := c.GetActivities(httpctx)
-
-			if err != nil {
-				log.Printf("GetActivities error: %s\n", err)
-				continue
-			}
-
-			for idx, activity := range res.Activities {
-				if idx > numActivities-1 {
-					break
-				}
+	events, err := c.Watch(ctx, intitools.WatchOptions{
+		Interval:  conf.tick,
+		Scheduler: scheduler,
+		SendLast:  conf.sendlast,
+	})
+	if err != nil {
+		return err
+	}
 
-				if conf.webhooktype == "slack" {
-					message, err := c.SlackFormatActivity(activity)
-					if err == nil {
-						err = c.SlackSend(message)
-						if err != nil {
-							log.Printf("Webhook send error: %s\n", err)
-							continue
-						}
-					}
-				} else {
-					message, err := c.DiscordFormatActivity(activity)
-					if err == nil {
-						err = c.DiscordSend(httpctx, message)
-						if err != nil {
-							log.Printf("Webhook send error: %s\n", err)
-							continue
-						}
-					}
-				}
+	if conf.catalog != "" {
+		catalog, err := intitools.OpenCatalog(conf.catalog)
+		if err != nil {
+			return err
+		}
+		programs, err := c.WatchCatalog(ctx, intitools.CatalogOptions{
+			Interval: conf.catalogtick,
+			Catalog:  catalog,
+		})
+		if err != nil {
+			return err
+		}
+		events = intitools.MergeEvents(ctx, events, programs)
+	}
 
-			}
+	if conf.metadata {
+		changes, err := c.WatchMetadata(ctx, intitools.MetadataOptions{
+			Interval: conf.metadatatick,
+			Programs: conf.follow,
+			Store:    c.Snapshots,
+		})
+		if err != nil {
+			return err
+		}
+		events = intitools.MergeEvents(ctx, events, changes)
+	}
 
-			c.LastViewed = time.Now().UTC().Unix()
+	p := intitools.NewPipeline(events)
+	if conf.enrich {
+		p.AddEnricher(c)
+	}
+	if conf.record != "" {
+		p.Use(intitools.RecordMiddleware(conf.record))
+	}
+	if conf.archive != "" {
+		ar, err := intitools.OpenArchive(conf.archive)
+		if err != nil {
+			return err
 		}
+		p.Use(intitools.ArchiveMiddleware(ar, c))
+	}
+	if conf.ledger != "" {
+		l, err := intitools.OpenLedger(conf.ledger)
+		if err != nil {
+			return err
+		}
+		p.Use(intitools.LedgerMiddleware(l))
+	}
+	for _, s := range sinks {
+		p.AddRoute(s.route(c))
+	}
+
+	if err := p.Run(ctx); err != nil && err != context.Canceled {
+		return err
 	}
 
+	return nil
 }
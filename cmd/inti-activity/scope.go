@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	intitools "github.com/0xJeti/intitools/pkg/intigo"
+	"github.com/namsral/flag"
+	"golang.org/x/time/rate"
+)
+
+// scopeCommand works with program scopes:
+//
+//	inti-activity scope export [-format hosts] [-type URL] [-tier 1,2] [-kind wildcard] COMPANY/HANDLE...
+//	inti-activity scope export -all -format burp > scope.json
+//	inti-activity scope lint [-all] COMPANY/HANDLE...
+func scopeCommand(args []string, out io.Writer) error {
+	if len(args) >= 2 {
+		switch args[1] {
+		case "export":
+			return scopeExportCommand(args[1:], out)
+		case "lint":
+			return scopeLintCommand(args[1:], out)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Usage of %s scope: export|lint [options] [COMPANY/HANDLE...]\n", os.Args[0])
+	os.Exit(1)
+	return nil
+}
+
+// programSource provides program details from the API or local snapshots
+type programSource struct {
+	flags     *flag.FlagSet
+	username  *string
+	password  *string
+	secret    *string
+	snapshots *string
+
+	client *intitools.Client
+	store  *intitools.SnapshotStore
+}
+
+func newProgramSource(flags *flag.FlagSet) *programSource {
+	return &programSource{
+		flags:     flags,
+		username:  flags.String("username", "", "Intigriti username (e-mail)"),
+		password:  flags.String("password", "", "Intigriti password"),
+		secret:    flags.String("secret", "", "Intigriti 2FA secret"),
+		snapshots: flags.String("snapshots", "", "Read programs from snapshots directory instead of the API"),
+	}
+}
+
+// open prepares the source after flags are parsed
+func (s *programSource) open() error {
+	if *s.snapshots != "" {
+		store, err := intitools.OpenSnapshotStore(*s.snapshots)
+		if err != nil {
+			return err
+		}
+		s.store = store
+		return nil
+	}
+
+	if *s.username == "" || *s.password == "" {
+		return fmt.Errorf("-username and -password (or -snapshots) are required")
+	}
+
+	rl := rate.NewLimiter(rate.Every(time.Second), 2) // 2 requests every second
+	s.client = intitools.NewClient(*s.username, *s.password, *s.secret, rl)
+	s.client.HttpCtx = context.Background()
+
+	return s.client.Authenticate()
+}
+
+// program returns details of the program
+func (s *programSource) program(ctx context.Context, company string, handle string) (*intitools.Program, error) {
+	if s.store != nil {
+		snap, err := s.store.Latest(company, handle)
+		if err != nil {
+			return nil, err
+		}
+		if snap == nil {
+			return nil, fmt.Errorf("no snapshots of %s/%s", company, handle)
+		}
+		return &snap.Program, nil
+	}
+
+	return s.client.GetProgram(ctx, company, handle)
+}
+
+// joined returns "company/handle" of all joined programs (or of all programs
+// with snapshots)
+func (s *programSource) joined(ctx context.Context) ([]string, error) {
+	if s.store != nil {
+		return s.store.Programs()
+	}
+
+	programs, err := s.client.GetJoinedPrograms(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	handles := make([]string, 0, len(programs))
+	for _, p := range programs {
+		handles = append(handles, p.CompanyHandle+"/"+p.Handle)
+	}
+	return handles, nil
+}
+
+// programs returns details of programs given as arguments or of all joined programs
+func (s *programSource) programs(ctx context.Context, all bool) ([]*intitools.Program, error) {
+	handles := s.flags.Args()
+	if all {
+		var err error
+		if handles, err = s.joined(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	programs := make([]*intitools.Program, 0, len(handles))
+	for _, h := range handles {
+		company, handle, err := splitProgram(h)
+		if err != nil {
+			return nil, err
+		}
+		p, err := s.program(ctx, company, handle)
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch program %s: %w", h, err)
+		}
+		programs = append(programs, p)
+	}
+
+	return programs, nil
+}
+
+func scopeExportCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet(args[0], flag.ExitOnError)
+
+	var (
+		format     = flags.String("format", "hosts", "Output format ["+strings.Join(intitools.ScopeFormats, "|")+"]")
+		types      = flags.String("type", "", "Endpoint types (comma separated, e.g. URL,IpRange)")
+		tiers      = flags.String("tier", "", "Bounty tiers (comma separated, e.g. 1,2)")
+		kinds      = flags.String("kind", "", "Asset kinds (comma separated, e.g. wildcard,iprange)")
+		outOfScope = flags.Bool("out-of-scope", false, "Export out of scope assets instead")
+		all        = flags.Bool("all", false, "Export all joined programs")
+		source     = newProgramSource(flags)
+	)
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 && !*all {
+		fmt.Fprintf(os.Stderr, "Usage of %s scope export: [options] COMPANY/HANDLE... | -all\n", os.Args[0])
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+
+	if err := source.open(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	programs, err := source.programs(ctx, *all)
+	if err != nil {
+		return err
+	}
+
+	scopes := make([]*intitools.ProgramScope, 0, len(programs))
+	for _, p := range programs {
+		scopes = append(scopes, intitools.NewProgramScope(p))
+	}
+
+	return intitools.ExportScope(out, scopes, *format, intitools.ScopeFilter{
+		Types:      splitList(*types),
+		Tiers:      splitList(*tiers),
+		Kinds:      splitList(*kinds),
+		OutOfScope: *outOfScope,
+	})
+}
+
+// scopeLintCommand lists domains contradicted by out of scope texts, domain
+// descriptions or other domains
+func scopeLintCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet(args[0], flag.ExitOnError)
+
+	var (
+		all    = flags.Bool("all", false, "Check all joined programs")
+		source = newProgramSource(flags)
+	)
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 && !*all {
+		fmt.Fprintf(os.Stderr, "Usage of %s scope lint: [options] COMPANY/HANDLE... | -all\n", os.Args[0])
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+
+	if err := source.open(); err != nil {
+		return err
+	}
+
+	programs, err := source.programs(context.Background(), *all)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, p := range programs {
+		conflicts := intitools.LintProgram(p)
+		total += len(conflicts)
+		if len(conflicts) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(out, "%s/%s (%s):\n", p.CompanyHandle, p.Handle, p.Name)
+		for _, sc := range conflicts {
+			fmt.Fprintf(out, "  %s\n", sc)
+		}
+	}
+
+	fmt.Fprintf(out, "%d conflicts in %d programs\n", total, len(programs))
+
+	return nil
+}
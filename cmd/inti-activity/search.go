@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	intitools "github.com/0xJeti/intitools/pkg/intigo"
+	"github.com/namsral/flag"
+)
+
+// searchCommand queries the local activity archive:
+//
+//	inti-activity search -archive DIR [-program HANDLE] [-type 25] [-since 90d] [TEXT...]
+func searchCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet(args[0], flag.ExitOnError)
+
+	var (
+		archive  = flags.String("archive", "", "Path to activity archive directory")
+		programs = flags.String("program", "", "Program handles (comma separated)")
+		company  = flags.String("company", "", "Company handles (comma separated)")
+		types    = flags.String("type", "", "Activity types (comma separated discriminators)")
+		since    = flags.String("since", "", "Start date (YYYY-MM-DD or age, e.g. 90d)")
+		until    = flags.String("until", "", "End date, exclusive (YYYY-MM-DD or age, e.g. 30d)")
+		limit    = flags.Int("limit", 0, "Show only N newest results")
+		asJSON   = flags.Bool("json", false, "Print matching records as JSON lines")
+		showDiff = flags.Bool("diff", false, "Print stored diffs")
+		dups     = flags.Bool("duplicates", false, "Print summary of submissions closed as duplicates per program")
+	)
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if *archive == "" {
+		fmt.Fprintf(os.Stderr, "Usage of %s search: -archive DIR [options] [TEXT...]\n", os.Args[0])
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+
+	q := intitools.ArchiveQuery{
+		Programs:  splitList(*programs),
+		Companies: splitList(*company),
+		Text:      strings.Join(flags.Args(), " "),
+		Limit:     *limit,
+	}
+
+	for _, t := range splitList(*types) {
+		d, err := strconv.Atoi(t)
+		if err != nil {
+			return fmt.Errorf("invalid activity type %q", t)
+		}
+		q.Discriminators = append(q.Discriminators, d)
+	}
+
+	var err error
+	if q.Since, err = parseDate(*since); err != nil {
+		return err
+	}
+	if q.Until, err = parseDate(*until); err != nil {
+		return err
+	}
+
+	ar, err := intitools.OpenArchive(*archive)
+	if err != nil {
+		return err
+	}
+
+	records, err := ar.Search(q)
+	if err != nil {
+		return err
+	}
+
+	if *dups {
+		fmt.Fprintf(out, "%-30s %8s %10s  %s\n", "PROGRAM", "CLOSED", "DUPLICATES", "ORIGINAL REPORTS")
+		for _, p := range intitools.DuplicateSummary(records) {
+			fmt.Fprintf(out, "%-30s %8d %10d  %s\n", p.ProgramHandle, p.Closed, p.Duplicates, strings.Join(p.Originals, ", "))
+		}
+		return nil
+	}
+
+	enc := json.NewEncoder(out)
+	for _, r := range records {
+		if *asJSON {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fmt.Fprintf(out, "%s  %-40s %-25s %s\n", r.Time().Format("2006-01-02 15:04"),
+			intitools.ActivityTypeName(r.Discriminator), r.ProgramHandle, r.Title)
+		if *showDiff && r.Diff != "" {
+			fmt.Fprintf(out, "%s\n\n", r.Diff)
+		}
+	}
+
+	if !*asJSON {
+		fmt.Fprintf(out, "%d records found\n", len(records))
+	}
+
+	return nil
+}
+
+// splitList splits comma separated list skipping empty items
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// parseDate parses YYYY-MM-DD (local time) or age in days (e.g. 90d)
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if strings.HasSuffix(s, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil {
+			return time.Now().AddDate(0, 0, -days), nil
+		}
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (expected YYYY-MM-DD or e.g. 90d)", s)
+	}
+	return t, nil
+}
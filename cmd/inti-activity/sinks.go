@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	intitools "github.com/0xJeti/intitools/pkg/intigo"
+)
+
+// sink is a single notification target with its own filter rules
+type sink struct {
+	Name    string            `json:"name"`
+	Type    string            `json:"type"` // slack, discord, json or hook
+	Webhook string            `json:"webhook"`
+	Filter  *intitools.Filter `json:"filter"`
+
+	// Hook sinks only
+	Command     []string `json:"command"`     // Program and its arguments
+	Timeout     string   `json:"timeout"`     // e.g. 30m (default 10m)
+	Concurrency int      `json:"concurrency"` // Maximum number of running commands (default 1)
+
+	timeout time.Duration
+}
+
+// loadSinks builds the list of sinks from the -webhook/-type/-filter options
+// and the optional -sinks file
+func loadSinks(conf *config) ([]*sink, error) {
+	var sinks []*sink
+
+	if conf.webhookurl != "" {
+		if _, ok := sinkTypes[conf.webhooktype]; !ok {
+			return nil, fmt.Errorf("unknown webhook type %q", conf.webhooktype)
+		}
+		s := &sink{Name: "default", Type: conf.webhooktype, Webhook: conf.webhookurl}
+		if conf.filter != "" {
+			f, err := intitools.LoadFilter(conf.filter)
+			if err != nil {
+				return nil, err
+			}
+			s.Filter = f
+		}
+		sinks = append(sinks, s)
+	}
+
+	if conf.sinks != "" {
+		extra, err := readSinks(conf.sinks)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, extra...)
+	}
+
+	return sinks, nil
+}
+
+// readSinks reads a JSON array of sinks
+func readSinks(path string) ([]*sink, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var sinks []*sink
+	if err := json.Unmarshal(data, &sinks); err != nil {
+		return nil, fmt.Errorf("cannot parse sinks %s: %w", path, err)
+	}
+
+	for i, s := range sinks {
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("sink%d", i+1)
+		}
+		if _, ok := sinkTypes[s.Type]; !ok {
+			return nil, fmt.Errorf("sink %s: unknown type %q", s.Name, s.Type)
+		}
+		if s.Type == "hook" {
+			if len(s.Command) == 0 {
+				return nil, fmt.Errorf("sink %s: command not defined", s.Name)
+			}
+			if s.Timeout != "" {
+				if s.timeout, err = time.ParseDuration(s.Timeout); err != nil {
+					return nil, fmt.Errorf("sink %s: invalid timeout: %w", s.Name, err)
+				}
+			}
+		} else if s.Webhook == "" {
+			return nil, fmt.Errorf("sink %s: webhook not defined", s.Name)
+		}
+		if s.Filter != nil {
+			if err := s.Filter.Compile(); err != nil {
+				return nil, fmt.Errorf("sink %s: %w", s.Name, err)
+			}
+		}
+	}
+
+	return sinks, nil
+}
+
+// sinkTypes maps sink type names to constructors
+var sinkTypes = map[string]func(c *intitools.Client, s *sink) intitools.Sink{
+	"slack": func(c *intitools.Client, s *sink) intitools.Sink {
+		return &intitools.SlackSink{Client: c, WebhookURL: s.Webhook}
+	},
+	"discord": func(c *intitools.Client, s *sink) intitools.Sink {
+		return &intitools.DiscordSink{Client: c, WebhookURL: s.Webhook}
+	},
+	"json": func(c *intitools.Client, s *sink) intitools.Sink {
+		return &intitools.JSONSink{Client: c, URL: s.Webhook}
+	},
+	"hook": func(c *intitools.Client, s *sink) intitools.Sink {
+		return intitools.NewHookSink(c, s.Command, s.timeout, s.Concurrency)
+	},
+}
+
+// route returns pipeline route delivering to the sink
+func (s *sink) route(c *intitools.Client) *intitools.Route {
+	r := &intitools.Route{
+		Name: s.Name,
+		Sink: sinkTypes[s.Type](c, s),
+	}
+	if s.Filter != nil {
+		r.Filter = s.Filter
+	}
+	return r
+}
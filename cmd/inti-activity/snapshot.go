@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	intitools "github.com/0xJeti/intitools/pkg/intigo"
+	"github.com/namsral/flag"
+)
+
+// snapshotCommand lists stored program snapshots and compares them:
+//
+//	inti-activity snapshot -snapshots DIR                           # programs
+//	inti-activity snapshot -snapshots DIR COMPANY/HANDLE            # versions
+//	inti-activity snapshot -snapshots DIR COMPANY/HANDLE OLD [NEW]  # diff (NEW defaults to latest)
+//	inti-activity snapshot -snapshots DIR -diff COMPANY/HANDLE      # diff of two latest versions
+func snapshotCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet(args[0], flag.ExitOnError)
+
+	var (
+		dir    = flags.String("snapshots", "", "Path to program snapshots directory")
+		latest = flags.Bool("diff", false, "Compare two latest snapshots of the program")
+	)
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if *dir == "" || flags.NArg() > 3 {
+		fmt.Fprintf(os.Stderr, "Usage of %s snapshot: -snapshots DIR [-diff] [COMPANY/HANDLE [OLD [NEW]]]\n", os.Args[0])
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+
+	store, err := intitools.OpenSnapshotStore(*dir)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		programs, err := store.Programs()
+		if err != nil {
+			return err
+		}
+		for _, p := range programs {
+			fmt.Fprintln(out, p)
+		}
+		return nil
+	}
+
+	company, handle, err := splitProgram(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	versions, err := store.Versions(company, handle)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("no snapshots of %s/%s", company, handle)
+	}
+
+	var oldVersion, newVersion string
+	switch {
+	case flags.NArg() >= 2:
+		oldVersion, newVersion = flags.Arg(1), versions[len(versions)-1]
+		if flags.NArg() == 3 {
+			newVersion = flags.Arg(2)
+		}
+	case *latest:
+		if len(versions) < 2 {
+			return fmt.Errorf("only one snapshot of %s/%s", company, handle)
+		}
+		oldVersion, newVersion = versions[len(versions)-2], versions[len(versions)-1]
+	default:
+		for _, v := range versions {
+			fmt.Fprintln(out, v)
+		}
+		return nil
+	}
+
+	oldSnap, err := store.Load(company, handle, oldVersion)
+	if err != nil {
+		return err
+	}
+	newSnap, err := store.Load(company, handle, newVersion)
+	if err != nil {
+		return err
+	}
+
+	changes := intitools.DiffPrograms(&oldSnap.Program, &newSnap.Program)
+	fmt.Fprintf(out, "%s/%s: %s → %s, %d changes\n", company, handle, oldVersion, newVersion, len(changes))
+	for _, fc := range changes {
+		fmt.Fprintf(out, "\n%s\n", fc.Unified())
+	}
+
+	return nil
+}
+
+// splitProgram splits "company/handle"
+func splitProgram(s string) (string, string, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid program %q (expected COMPANY/HANDLE)", s)
+	}
+	return parts[0], parts[1], nil
+}
@@ -20,10 +20,14 @@ package intitools
 
 */
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"time"
 )
 
 type ActivityList struct {
@@ -53,6 +57,167 @@ type Activity struct {
 	Programhandle   string         `json:"programHandle"`
 	Companyhandle   string         `json:"companyHandle"`
 	Newendpoint     string         `json:"newEndpointVulnerableComponent"`
+
+	// Raw holds the activity exactly as received from the API so that
+	// unknown activity types can be inspected later.
+	Raw json.RawMessage `json:"-"`
+}
+
+// Time returns the activity creation time (CreatedAt is in milliseconds)
+func (a Activity) Time() time.Time {
+	return time.Unix(0, a.CreatedAt*int64(time.Millisecond))
+}
+
+// activityTypes names all discriminators handled by the formatters
+var activityTypes = map[int]string{
+	1:  "Submission - New message",
+	2:  "Submission - Status change",
+	3:  "Submission - Change severity",
+	5:  "Submission - Payout",
+	7:  "Submission - Change vulnerable endpoint",
+	8:  "Submission - Change vulnerability type",
+	9:  "Submission - Requires additional feedback",
+	10: "Submission - Provided feedback",
+	11: "Submission - Stopped requesting feedback",
+	20: "Program - Status change",
+	22: "Program - Update description",
+	23: "Program - Update bounties",
+	24: "Program - Update scope",
+	25: "Program - Update out of scope",
+	26: "Program - Update FAQ",
+	27: "Program - Update domains",
+	28: "Program - Update rules of engagement",
+	29: "Program - Update severity assessment",
+	47: "Program - Update published",
+
+	ActivityNewProgram:        "Catalog - New program",
+	ActivityProgramInvitation: "Catalog - Program invitation",
+	ActivityProgramMetadata:   "Program - Metadata update",
+}
+
+// IsKnownActivity reports whether the discriminator is supported by the formatters
+func IsKnownActivity(discriminator int) bool {
+	_, ok := activityTypes[discriminator]
+	return ok
+}
+
+// ActivityTypeName returns human readable name of the activity type
+func ActivityTypeName(discriminator int) string {
+	if name, ok := activityTypes[discriminator]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown: %d", discriminator)
+}
+
+// UnmarshalJSON decodes the activity and keeps a copy of its raw payload
+func (a *Activity) UnmarshalJSON(data []byte) error {
+	type activity Activity
+
+	var v activity
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*a = Activity(v)
+	a.Raw = append(json.RawMessage(nil), data...)
+
+	return nil
+}
+
+// recordedActivity is a single line of an activity log file
+type recordedActivity struct {
+	LoggedAt      string          `json:"loggedAt"`
+	Discriminator int             `json:"discriminator"`
+	Activity      json.RawMessage `json:"activity"`
+}
+
+// RecordActivity appends the raw activity as a JSON line to the file at path.
+// Files written this way can be read back with ReadActivities.
+func RecordActivity(path string, a Activity) error {
+	raw := a.Raw
+	if len(raw) == 0 {
+		var err error
+		if raw, err = json.Marshal(a); err != nil {
+			return err
+		}
+	}
+
+	return appendJSONLine(path, recordedActivity{
+		LoggedAt:      time.Now().UTC().Format(time.RFC3339),
+		Discriminator: a.Discriminator,
+		Activity:      raw,
+	})
+}
+
+// LogUnknownActivity appends the raw activity to the unrecognized activities log
+func (c *Client) LogUnknownActivity(a Activity) error {
+	if c.UnknownLog == "" {
+		return nil
+	}
+
+	return RecordActivity(c.UnknownLog, a)
+}
+
+// ReadActivities decodes activities from r. It accepts activity logs written by
+// RecordActivity as well as plain activities, arrays of activities and
+// activity feed responses.
+func ReadActivities(r io.Reader) ([]Activity, error) {
+	var activities []Activity
+
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		raw = bytes.TrimSpace(raw)
+		if len(raw) > 0 && raw[0] == '[' {
+			var list []Activity
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return nil, err
+			}
+			activities = append(activities, list...)
+			continue
+		}
+
+		var probe struct {
+			Activities []Activity      `json:"activities"`
+			Activity   json.RawMessage `json:"activity"`
+		}
+		if err := json.Unmarshal(raw, &probe); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case probe.Activities != nil:
+			activities = append(activities, probe.Activities...)
+		case probe.Activity != nil:
+			raw = probe.Activity
+			fallthrough
+		default:
+			var a Activity
+			if err := json.Unmarshal(raw, &a); err != nil {
+				return nil, err
+			}
+			activities = append(activities, a)
+		}
+	}
+
+	return activities, nil
+}
+
+// unknownActivityMessage returns notification text for unsupported activity types.
+// Raw payload is attached only if AttachUnknown is set, formatted by
+// codeBlock of the webhook (e.g. slackCodeBlock).
+func (c *Client) unknownActivityMessage(a Activity, codeBlock func(string, int) string) string {
+	message := fmt.Sprintf("Unknown message type: %d", a.Discriminator)
+	if c.AttachUnknown && len(a.Raw) > 0 {
+		message += "\n" + codeBlock(string(a.Raw), 1500)
+	}
+	return message
 }
 
 type ActivityOptions struct {
@@ -102,68 +267,75 @@ func (c *Client) CheckActivity() (int, error) {
 	}
 	return res, nil
 }
-func (c *Client) GetSubmissionState(state int) string {
-	submmissionStates := []string{
-		"Dummy",
-		"Triage",
-		"Pending",
-		"Accepted",
-		"Closed",
-		"Archived",
-		"Unknown: 6",
-		"Unknown: 7",
-	}
 
-	return submmissionStates[state]
+var submissionStates = []string{
+	"Dummy",
+	"Triage",
+	"Pending",
+	"Accepted",
+	"Closed",
+	"Archived",
+	"Unknown: 6",
+	"Unknown: 7",
 }
 
-func (c *Client) GetClosedState(state int) string {
-	closedStates := []string{
-		"Dummy",
-		"Resolved",
-		"Duplicate",
-		"Accepted Risk",
-		"Informative",
-		"Out Of Scope",
-		"Spam",
-		"Not Applicable",
+var closedStates = []string{
+	"Dummy",
+	"Resolved",
+	"Duplicate",
+	"Accepted Risk",
+	"Informative",
+	"Out Of Scope",
+	"Spam",
+	"Not Applicable",
+}
+
+var severityIds = []string{
+	"Dummy",
+	"Undecided",
+	"Low",
+	"Medium",
+	"High",
+	"Critical",
+	"Exceptional",
+	"Undecided",
+	"Unknown: 8",
+}
+
+// lookupName returns names[id] or "Unknown: id" if id is out of range
+func lookupName(names []string, id int) string {
+	if id < 0 || id >= len(names) {
+		return fmt.Sprintf("Unknown: %d", id)
 	}
+	return names[id]
+}
 
-	return closedStates[state]
+func (c *Client) GetSubmissionState(state int) string {
+	return lookupName(submissionStates, state)
+}
+
+func (c *Client) GetClosedState(state int) string {
+	return lookupName(closedStates, state)
 }
 
 func (c *Client) GetSeverity(severity int) string {
-	severityIds := []string{
-		"Dummy",
-		"Undecided",
-		"Low",
-		"Medium",
-		"High",
-		"Critical",
-		"Exceptional",
-		"Undecided",
-		"Unknown: 8",
-	}
+	return lookupName(severityIds, severity)
+}
 
-	return severityIds[severity]
+var programStates = map[int]string{
+	0:    "Dummy",
+	3:    "Open",
+	4:    "Suspended",
+	5:    "Closing",
+	6:    "Closed",
+	7:    "Deleted",
+	1001: "Draft",
+	1002: "Enrolling",
+	1003: "Open",
+	1004: "Closing",
+	1005: "Closed",
 }
 
 func (c *Client) GetProgramState(program int) string {
-	programStates := [1006]string{
-		"Dummy",
-		"Unknown: 1",
-		"Unknown: 2",
-		"Open",
-		"Suspended",
-		"Closing",
-		"Closed",
-		"Deleted",
-	}
-	programStates[1001] = "Draft"
-	programStates[1002] = "Enrolling"
-	programStates[1003] = "Open"
-	programStates[1004] = "Closing"
-	programStates[1005] = "Closed"
-
-	return programStates[program]
+	return programState(program)
 }
@@ -0,0 +1,22 @@
+package intitools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnknownActivityMessage(t *testing.T) {
+	a := Activity{Discriminator: 99, Raw: []byte("{\"title\":\"```<@here>```\"}")}
+
+	if got := (&Client{}).unknownActivityMessage(a, slackCodeBlock); got != "Unknown message type: 99" {
+		t.Errorf("without AttachUnknown = %q", got)
+	}
+
+	c := &Client{AttachUnknown: true}
+	for name, codeBlock := range map[string]func(string, int) string{"slack": slackCodeBlock, "discord": discordCodeBlock} {
+		got := c.unknownActivityMessage(a, codeBlock)
+		if n := strings.Count(got, "```"); n != 2 {
+			t.Errorf("%s: %q has %d code fences, want 2", name, got, n)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package intitools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Archive is a local store of received activities. Records are kept as JSON
+// lines in monthly segment files (YYYY-MM.jsonl) inside the archive directory.
+type Archive struct {
+	dir string
+	mu  sync.Mutex
+}
+
+type ArchiveRecord struct {
+	Key            string          `json:"key"`
+	ArchivedAt     time.Time       `json:"archivedAt"`
+	CreatedAt      int64           `json:"createdAt"` // Activity creation time in milliseconds
+	Discriminator  int             `json:"discriminator"`
+	ProgramId      string          `json:"programId"`
+	ProgramHandle  string          `json:"programHandle"`
+	ProgramName    string          `json:"programName"`
+	CompanyHandle  string          `json:"companyHandle"`
+	SubmissionCode string          `json:"submissionCode,omitempty"`
+	Title          string          `json:"title,omitempty"`
+	Description    string          `json:"description,omitempty"`
+	Diff           string          `json:"diff,omitempty"` // Diff rendered as plain text
+	ProgramDiff    *ProgramDiff    `json:"programDiff,omitempty"`
+	DuplicateOf    string          `json:"duplicateOf,omitempty"` // Original report of a duplicate
+	Transition     *Transition     `json:"transition,omitempty"`
+	Activity       json.RawMessage `json:"activity"`
+}
+
+// ProgramDuplicates summarizes how often a program closed our reports as duplicates
+type ProgramDuplicates struct {
+	ProgramHandle string
+	ProgramName   string
+	Closed        int      // Submissions closed for any reason
+	Duplicates    int      // Submissions closed as duplicate
+	Originals     []string // Original reports of the duplicates
+}
+
+type ArchiveQuery struct {
+	Programs       []string  // Program handles
+	Companies      []string  // Company handles
+	Discriminators []int     // Activity types
+	Since          time.Time // Inclusive
+	Until          time.Time // Exclusive
+	Text           string    // Words and "quoted phrases", all must match (case-insensitive)
+	Limit          int       // Maximum number of (newest) records, 0 for all
+}
+
+// OpenArchive opens (and creates if needed) the archive in directory dir
+func OpenArchive(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Archive{dir: dir}, nil
+}
+
+// Time returns the activity creation time
+func (r ArchiveRecord) Time() time.Time {
+	return time.Unix(0, r.CreatedAt*int64(time.Millisecond))
+}
+
+// NewArchiveRecord builds an archive record of the event. The diff text is
+// rendered with the display time zone and format of c (defaults if nil).
+func NewArchiveRecord(e ActivityEvent, c *Client) ArchiveRecord {
+	a := e.Activity
+
+	raw := a.Raw
+	if len(raw) == 0 {
+		raw, _ = json.Marshal(a)
+	}
+
+	title := a.Title
+	if title == "" {
+		title = a.Submissiontitle
+	}
+
+	diff := ""
+	switch {
+	case e.Diff == nil:
+	case c != nil:
+		diff = c.DiffString(e.Diff)
+	default:
+		diff = e.Diff.String()
+	}
+
+	return ArchiveRecord{
+		Key:            activityKey(a),
+		ArchivedAt:     time.Now().UTC(),
+		CreatedAt:      a.CreatedAt,
+		Discriminator:  a.Discriminator,
+		ProgramId:      a.Programid,
+		ProgramHandle:  a.Programhandle,
+		ProgramName:    a.Programname,
+		CompanyHandle:  a.Companyhandle,
+		SubmissionCode: a.Submissioncode,
+		Title:          title,
+		Description:    a.Description,
+		Diff:           diff,
+		ProgramDiff:    e.Diff,
+		DuplicateOf:    duplicateOf(e),
+		Transition:     a.Transition(),
+		Activity:       raw,
+	}
+}
+
+func duplicateOf(e ActivityEvent) string {
+	if e.Duplicate != nil {
+		return e.Duplicate.Code
+	}
+	return duplicateReference(e.Activity, e.Submission)
+}
+
+// DuplicateSummary counts submissions closed as duplicates per program,
+// ordered by the number of duplicates
+func DuplicateSummary(records []ArchiveRecord) []ProgramDuplicates {
+	programs := make(map[string]*ProgramDuplicates)
+	var order []string
+
+	for _, r := range records {
+		if r.Discriminator != 2 {
+			continue
+		}
+
+		var a Activity
+		if err := json.Unmarshal(r.Activity, &a); err != nil || a.Newstate.Status != 4 {
+			continue
+		}
+
+		p, ok := programs[r.ProgramHandle]
+		if !ok {
+			p = &ProgramDuplicates{ProgramHandle: r.ProgramHandle, ProgramName: r.ProgramName}
+			programs[r.ProgramHandle] = p
+			order = append(order, r.ProgramHandle)
+		}
+
+		p.Closed++
+		if a.Newstate.Closereason == 2 {
+			p.Duplicates++
+			if r.DuplicateOf != "" {
+				p.Originals = append(p.Originals, r.DuplicateOf)
+			}
+		}
+	}
+
+	summary := make([]ProgramDuplicates, 0, len(order))
+	for _, handle := range order {
+		summary = append(summary, *programs[handle])
+	}
+	sort.SliceStable(summary, func(i, j int) bool {
+		return summary[i].Duplicates > summary[j].Duplicates
+	})
+
+	return summary
+}
+
+// Add stores the record in the segment of its creation month
+func (ar *Archive) Add(r ArchiveRecord) error {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	t := r.Time()
+	if r.CreatedAt == 0 {
+		t = r.ArchivedAt
+	}
+
+	return appendJSONLine(filepath.Join(ar.dir, t.UTC().Format("2006-01")+".jsonl"), r)
+}
+
+// Search returns records matching the query ordered from the oldest one
+func (ar *Archive) Search(q ArchiveQuery) ([]ArchiveRecord, error) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	segments, err := filepath.Glob(filepath.Join(ar.dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(segments)
+
+	terms := searchTerms(q.Text)
+	seen := make(map[string]bool)
+
+	var records []ArchiveRecord
+	for _, segment := range segments {
+		if !q.segmentInRange(strings.TrimSuffix(filepath.Base(segment), ".jsonl")) {
+			continue
+		}
+
+		err := readArchiveSegment(segment, func(r ArchiveRecord) {
+			if seen[r.Key] || !q.matches(r, terms) {
+				return
+			}
+			seen[r.Key] = true
+			records = append(records, r)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].CreatedAt < records[j].CreatedAt
+	})
+
+	if q.Limit > 0 && len(records) > q.Limit {
+		records = records[len(records)-q.Limit:]
+	}
+
+	return records, nil
+}
+
+func readArchiveSegment(path string, fn func(r ArchiveRecord)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var r ArchiveRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		fn(r)
+	}
+
+	return scanner.Err()
+}
+
+// segmentInRange reports whether segment month (YYYY-MM) may contain records
+// within the query date range
+func (q ArchiveQuery) segmentInRange(month string) bool {
+	start, err := time.Parse("2006-01", month)
+	if err != nil {
+		return true
+	}
+	end := start.AddDate(0, 1, 0)
+
+	if !q.Since.IsZero() && !end.After(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !start.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+func (q ArchiveQuery) matches(r ArchiveRecord, terms []string) bool {
+	if len(q.Programs) > 0 && !containsFold(q.Programs, r.ProgramHandle) {
+		return false
+	}
+	if len(q.Companies) > 0 && !containsFold(q.Companies, r.CompanyHandle) {
+		return false
+	}
+	if len(q.Discriminators) > 0 && !containsInt(q.Discriminators, r.Discriminator) {
+		return false
+	}
+
+	t := r.Time()
+	if !q.Since.IsZero() && t.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !t.Before(q.Until) {
+		return false
+	}
+
+	if len(terms) > 0 {
+		text := strings.ToLower(strings.Join([]string{
+			r.ProgramName, r.ProgramHandle, r.CompanyHandle, r.SubmissionCode, r.Title, r.Description, r.Diff,
+		}, "\n"))
+		for _, term := range terms {
+			if !strings.Contains(text, term) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// searchTerms splits text into lowercase words and "quoted phrases"
+func searchTerms(text string) []string {
+	var terms []string
+
+	for i, part := range strings.Split(text, `"`) {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		// Odd parts are inside quotes
+		if i%2 == 1 {
+			terms = append(terms, part)
+			continue
+		}
+		terms = append(terms, strings.Fields(part)...)
+	}
+
+	return terms
+}
+
+// ArchiveMiddleware stores every event passing through the pipeline in the
+// archive. Program diffs are fetched (and attached to the event) if missing.
+func ArchiveMiddleware(ar *Archive, c *Client) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, e *ActivityEvent) error {
+			if e.Diff == nil && c != nil {
+				d, err := c.ProgramActivityDiff(e.Activity)
+				if err != nil {
+					log.Printf("Archive error: %s\n", err)
+				}
+				e.Diff = d
+			}
+			if err := ar.Add(NewArchiveRecord(*e, c)); err != nil {
+				log.Printf("Archive error: %s\n", err)
+			}
+			return next(ctx, e)
+		}
+	}
+}
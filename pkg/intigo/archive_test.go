@@ -0,0 +1,21 @@
+package intitools
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewArchiveRecordTimeZone(t *testing.T) {
+	loc := time.FixedZone("UTC+5", 5*60*60)
+	c := &Client{Location: loc, TimeFormat: "2006-01-02 15:04 MST"}
+
+	d := newTextDiff("InScopes", DiffLines, time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC), time.Date(2024, 1, 11, 12, 0, 0, 0, time.UTC), "a", "b")
+	r := NewArchiveRecord(ActivityEvent{Activity: Activity{Discriminator: 24}, Diff: d}, c)
+
+	for _, want := range []string{"2024-01-10 17:00 UTC+5", "2024-01-11 17:00 UTC+5"} {
+		if !strings.Contains(r.Diff, want) {
+			t.Errorf("diff %q does not contain %q", r.Diff, want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package intitools
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Asset kinds returned by ParseAsset
+const (
+	AssetWildcard = "wildcard" // e.g. *.example.com, Host is the root domain
+	AssetHost     = "host"     // Exact host name
+	AssetURL      = "url"      // URL with path, port or non-https scheme
+	AssetIPRange  = "iprange"  // IP address, CIDR or range, see CIDRs
+	AssetApp      = "app"      // Mobile app bundle / package id or store id
+	AssetText     = "text"     // Anything else
+)
+
+// Asset is a parsed program endpoint
+type Asset struct {
+	Kind  string   `json:"kind"`
+	Value string   `json:"value"`           // Normalized form, e.g. "*.example.com" or "https://example.com/api"
+	Host  string   `json:"host,omitempty"`  // Host name (wildcard root for wildcards)
+	CIDRs []string `json:"cidrs,omitempty"` // Networks of IP ranges
+	Raw   string   `json:"raw"`             // Part of the endpoint the asset was parsed from
+}
+
+// Key identifies the asset for deduplication
+func (a Asset) Key() string {
+	return a.Kind + " " + a.Value
+}
+
+var (
+	hostPattern    = regexp.MustCompile(`^[a-z0-9]([a-z0-9_-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9_-]*[a-z0-9])?)*$`)
+	appIdPattern   = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*(\.[A-Za-z0-9_]+)+$`)
+	appleIdPattern = regexp.MustCompile(`/id(\d+)`)
+)
+
+// ParseAsset parses an endpoint of given type (see endpointTypes) into typed
+// assets. Endpoints listing several values (separated by commas, spaces or
+// new lines) give several assets.
+func ParseAsset(endpointType int, endpoint string) []Asset {
+	typeName := lookupName(endpointTypes, endpointType)
+
+	var assets []Asset
+	for _, part := range splitEndpoint(endpoint) {
+		var a Asset
+		switch typeName {
+		case "Android", "iOS":
+			a = parseApp(part)
+		case "Device", "Other":
+			a = Asset{Kind: AssetText, Value: part}
+		default:
+			a = parseNetworkAsset(part)
+		}
+		a.Raw = part
+		assets = append(assets, a)
+	}
+
+	// Free text (e.g. "All our websites") is kept as a single asset
+	for _, a := range assets {
+		if a.Kind == AssetText && len(assets) > 1 {
+			text := strings.TrimSpace(endpoint)
+			return []Asset{{Kind: AssetText, Value: text, Raw: text}}
+		}
+	}
+
+	return assets
+}
+
+// ParseDomain parses the program domain
+func ParseDomain(d ProgramDomainsContent) []Asset {
+	return ParseAsset(d.Type, d.Endpoint)
+}
+
+// splitEndpoint splits endpoint listing several values
+func splitEndpoint(endpoint string) []string {
+	return strings.FieldsFunc(endpoint, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\n' || r == '\t' || r == '\r'
+	})
+}
+
+// parseNetworkAsset parses URL and IP range endpoints
+func parseNetworkAsset(s string) Asset {
+	if cidrs := parseIPRange(s); len(cidrs) > 0 {
+		return Asset{Kind: AssetIPRange, Value: strings.Join(cidrs, ","), CIDRs: cidrs}
+	}
+
+	raw := s
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return Asset{Kind: AssetText, Value: raw}
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	port := u.Port()
+	if (u.Scheme == "https" && port == "443") || (u.Scheme == "http" && port == "80") {
+		port = ""
+	}
+
+	// Wildcards: *.example.com, *example.com or .example.com
+	wildcard := false
+	for _, prefix := range []string{"*.", "*", "."} {
+		if strings.HasPrefix(host, prefix) {
+			host = strings.TrimPrefix(host, prefix)
+			wildcard = true
+			break
+		}
+	}
+	if !hostPattern.MatchString(host) {
+		if ip := net.ParseIP(host); ip != nil {
+			return Asset{Kind: AssetIPRange, Value: singleCIDR(ip), CIDRs: []string{singleCIDR(ip)}}
+		}
+		return Asset{Kind: AssetText, Value: raw}
+	}
+	if !strings.Contains(host, ".") {
+		return Asset{Kind: AssetText, Value: raw}
+	}
+
+	path := strings.TrimSuffix(u.EscapedPath(), "/")
+	if wildcard && port == "" && path == "" {
+		return Asset{Kind: AssetWildcard, Value: "*." + host, Host: host}
+	}
+	if wildcard {
+		host = "*." + host
+	}
+
+	if port == "" && path == "" && u.RawQuery == "" && u.Scheme == "https" {
+		return Asset{Kind: AssetHost, Value: host, Host: strings.TrimPrefix(host, "*.")}
+	}
+
+	hostport := host
+	if port != "" {
+		hostport = net.JoinHostPort(host, port)
+	}
+	norm := url.URL{Scheme: u.Scheme, Host: hostport, Path: u.Path, RawQuery: u.RawQuery}
+	norm.Path = strings.TrimSuffix(norm.Path, "/")
+
+	return Asset{Kind: AssetURL, Value: norm.String(), Host: strings.TrimPrefix(host, "*.")}
+}
+
+// parseIPRange parses IP address, CIDR or "first-last" IPv4 range
+func parseIPRange(s string) []string {
+	if _, n, err := net.ParseCIDR(s); err == nil {
+		return []string{n.String()}
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		return []string{singleCIDR(ip)}
+	}
+	if i := strings.Index(s, "-"); i > 0 {
+		return rangeCIDRs(net.ParseIP(strings.TrimSpace(s[:i])), net.ParseIP(strings.TrimSpace(s[i+1:])))
+	}
+	return nil
+}
+
+// parseApp parses app package / bundle id or store URL
+func parseApp(s string) Asset {
+	if u, err := url.Parse(s); err == nil && u.Host != "" {
+		if id := u.Query().Get("id"); id != "" {
+			return Asset{Kind: AssetApp, Value: id}
+		}
+		if m := appleIdPattern.FindStringSubmatch(u.Path); m != nil {
+			return Asset{Kind: AssetApp, Value: "id" + m[1]}
+		}
+		return Asset{Kind: AssetText, Value: s}
+	}
+	if appIdPattern.MatchString(s) {
+		return Asset{Kind: AssetApp, Value: s}
+	}
+	return Asset{Kind: AssetText, Value: s}
+}
+
+// DedupAssets drops repeated assets and assets covered by a wildcard (e.g.
+// a.example.com when *.example.com is listed). Order is kept.
+func DedupAssets(assets []Asset) []Asset {
+	var roots []string
+	for _, a := range assets {
+		if a.Kind == AssetWildcard {
+			roots = append(roots, a.Host)
+		}
+	}
+	sort.Strings(roots)
+
+	seen := make(map[string]bool)
+	var list []Asset
+	for _, a := range assets {
+		if seen[a.Key()] {
+			continue
+		}
+		seen[a.Key()] = true
+		if a.Kind == AssetHost && coveredByWildcard(a.Host, roots) {
+			continue
+		}
+		list = append(list, a)
+	}
+
+	return list
+}
+
+func coveredByWildcard(host string, roots []string) bool {
+	for _, root := range roots {
+		if strings.HasSuffix(host, "."+root) {
+			return true
+		}
+	}
+	return false
+}
+
+// AssetKinds returns distinct kinds of the assets
+func AssetKinds(assets []Asset) []string {
+	var kinds []string
+	for _, a := range assets {
+		if !containsFold(kinds, a.Kind) {
+			kinds = append(kinds, a.Kind)
+		}
+	}
+	return kinds
+}
@@ -0,0 +1,61 @@
+package intitools
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAsset(t *testing.T) {
+	tests := []struct {
+		endpointType int
+		endpoint     string
+		want         []Asset
+	}{
+		{1, "*.example.com", []Asset{{Kind: AssetWildcard, Value: "*.example.com", Host: "example.com", Raw: "*.example.com"}}},
+		{1, "*.example.com/api", []Asset{{Kind: AssetURL, Value: "https://*.example.com/api", Host: "example.com", Raw: "*.example.com/api"}}},
+		{1, "*.example.com:8443", []Asset{{Kind: AssetURL, Value: "https://*.example.com:8443", Host: "example.com", Raw: "*.example.com:8443"}}},
+		{1, "https://App.Example.com/", []Asset{{Kind: AssetHost, Value: "app.example.com", Host: "app.example.com", Raw: "https://App.Example.com/"}}},
+		{1, "app.example.com:8443/api", []Asset{{Kind: AssetURL, Value: "https://app.example.com:8443/api", Host: "app.example.com", Raw: "app.example.com:8443/api"}}},
+		{1, "a.example.com, b.example.com", []Asset{
+			{Kind: AssetHost, Value: "a.example.com", Host: "a.example.com", Raw: "a.example.com"},
+			{Kind: AssetHost, Value: "b.example.com", Host: "b.example.com", Raw: "b.example.com"},
+		}},
+		{4, "10.0.0.0-10.0.0.3", []Asset{{Kind: AssetIPRange, Value: "10.0.0.0/30", CIDRs: []string{"10.0.0.0/30"}, Raw: "10.0.0.0-10.0.0.3"}}},
+		{2, "com.example.app", []Asset{{Kind: AssetApp, Value: "com.example.app", Raw: "com.example.app"}}},
+		{1, "All our websites", []Asset{{Kind: AssetText, Value: "All our websites", Raw: "All our websites"}}},
+	}
+
+	for _, tt := range tests {
+		if got := ParseAsset(tt.endpointType, tt.endpoint); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseAsset(%d, %q) = %+v, want %+v", tt.endpointType, tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func TestExportScope(t *testing.T) {
+	scope := &ProgramScope{}
+	for _, endpoint := range []string{"*.example.com/api", "*.example.com:8443", "*.wild.com", "www.wild.com", "app.example.com/v1"} {
+		scope.Assets = append(scope.Assets, newScopeAsset(ProgramDomainsContent{Type: 1, Endpoint: endpoint, BountyTierId: 2}))
+	}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"hosts", []string{"app.example.com"}},
+		{"wildcards", []string{"wild.com"}},
+		{"urls", []string{"https://app.example.com/v1"}},
+	}
+
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := ExportScope(&b, []*ProgramScope{scope}, tt.format, ScopeFilter{}); err != nil {
+			t.Fatalf("ExportScope(%s): %s", tt.format, err)
+		}
+		if got := strings.Fields(b.String()); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExportScope(%s) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package intitools
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ProgramBountyTables is a version of the program bounty table
+type ProgramBountyTables struct {
+	CreatedAt int64              `json:"createdAt"`
+	Content   ProgramBountyTable `json:"content"`
+}
+
+// ProgramBountyTable lists bounties per bounty tier and severity
+type ProgramBountyTable struct {
+	Currency   string      `json:"currency"`
+	BountyRows []BountyRow `json:"bountyRows"`
+}
+
+type BountyRow struct {
+	BountyTierId int           `json:"bountyTierId"`
+	Values       []BountyValue `json:"bountyValues"`
+}
+
+type BountyValue struct {
+	SeverityId int            `json:"severityId"`
+	Value      ResponsePayout `json:"value"`
+}
+
+// BountyCell is a single bounty of the table, e.g. Tier 1 Critical
+type BountyCell struct {
+	Tier     int
+	Severity int
+	Amount   ResponsePayout
+}
+
+// Name returns e.g. "Tier 1 Critical"
+func (b BountyCell) Name() string {
+	return fmt.Sprintf("%s %s", lookupName(endpointTiers, b.Tier), lookupName(severityIds, b.Severity))
+}
+
+// Cells returns all bounties of the table ordered from the highest tier and severity
+func (t ProgramBountyTable) Cells() []BountyCell {
+	var cells []BountyCell
+
+	for _, row := range t.BountyRows {
+		for _, v := range row.Values {
+			amount := v.Value
+			if amount.Currency == "" {
+				amount.Currency = t.Currency
+			}
+			cells = append(cells, BountyCell{Tier: row.BountyTierId, Severity: v.SeverityId, Amount: amount})
+		}
+	}
+
+	// Tier ids grow from "No Bounty Tier" to "Tier 1" (see endpointTiers)
+	sort.SliceStable(cells, func(i, j int) bool {
+		if cells[i].Tier != cells[j].Tier {
+			return cells[i].Tier > cells[j].Tier
+		}
+		return cells[i].Severity > cells[j].Severity
+	})
+
+	return cells
+}
+
+// Range returns the lowest and highest non-zero bounty of the table
+func (t ProgramBountyTable) Range() (ResponsePayout, ResponsePayout) {
+	var min, max ResponsePayout
+
+	for _, cell := range t.Cells() {
+		if cell.Amount.Value <= 0 {
+			continue
+		}
+		if min.Currency == "" || cell.Amount.Value < min.Value {
+			min = cell.Amount
+		}
+		if cell.Amount.Value > max.Value {
+			max = cell.Amount
+		}
+	}
+
+	return min, max
+}
+
+func formatBounty(p ResponsePayout) string {
+	if p.Currency == "" && p.Value == 0 {
+		return "-"
+	}
+	return FormatMoney(p.Currency, p.Value)
+}
+
+// bountyChanges returns changed bounties (e.g. "Tier 1 Critical: €5,000 →
+// €7,500") and changes of the bounty range
+func bountyChanges(old ProgramBountyTable, new ProgramBountyTable) []FieldChange {
+	var changes []FieldChange
+
+	oldCells := make(map[[2]int]ResponsePayout)
+	for _, cell := range old.Cells() {
+		oldCells[[2]int{cell.Tier, cell.Severity}] = cell.Amount
+	}
+
+	seen := make(map[[2]int]bool)
+	for _, cell := range new.Cells() {
+		key := [2]int{cell.Tier, cell.Severity}
+		seen[key] = true
+		if o := oldCells[key]; o != cell.Amount {
+			changes = append(changes, FieldChange{Field: cell.Name(), Old: formatBounty(o), New: formatBounty(cell.Amount)})
+		}
+	}
+	for _, cell := range old.Cells() {
+		if !seen[[2]int{cell.Tier, cell.Severity}] {
+			changes = append(changes, FieldChange{Field: cell.Name(), Old: formatBounty(cell.Amount), New: "-"})
+		}
+	}
+
+	oldMin, oldMax := old.Range()
+	newMin, newMax := new.Range()
+	if oldMin != newMin {
+		changes = append(changes, FieldChange{Field: "Minimum bounty", Old: formatBounty(oldMin), New: formatBounty(newMin)})
+	}
+	if oldMax != newMax {
+		changes = append(changes, FieldChange{Field: "Maximum bounty", Old: formatBounty(oldMax), New: formatBounty(newMax)})
+	}
+
+	return changes
+}
+
+// latestBounties returns the newest bounty table
+func latestBounties(tables []ProgramBountyTables) ProgramBountyTable {
+	created := make([]int64, len(tables))
+	for i, t := range tables {
+		created[i] = t.CreatedAt
+	}
+	if i := newestVersion(created); i >= 0 {
+		return tables[i].Content
+	}
+	return ProgramBountyTable{}
+}
+
+// GetProgramBountiesDiff returns bounties changed by the activity
+func (c *Client) GetProgramBountiesDiff(a Activity) (*ProgramDiff, error) {
+
+	res, err := c.activityProgram(a)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := res.BountyTables
+	if len(tables) == 0 {
+		return historyUnavailable("Bounties", a, "program has no history of bounties"), nil
+	}
+
+	created := make([]int64, len(tables))
+	for i, t := range tables {
+		created[i] = t.CreatedAt
+	}
+
+	idx, prev := matchHistory(created, a.CreatedAt/1000) // Versions are in seconds
+	if idx < 0 {
+		return historyUnavailable("Bounties", a, "no version matches the activity time"), nil
+	}
+
+	d := &ProgramDiff{Section: "Bounties", To: time.Unix(created[idx], 0)}
+	old := ProgramBountyTable{}
+	if prev < 0 {
+		d.First = true
+	} else {
+		d.From = time.Unix(created[prev], 0)
+		old = tables[prev].Content
+	}
+	d.Fields = bountyChanges(old, tables[idx].Content)
+
+	return d, nil
+}
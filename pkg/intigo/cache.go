@@ -0,0 +1,64 @@
+package intitools
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// programCache keeps the last fetched version of every program
+type programCache struct {
+	mu      sync.Mutex
+	entries map[string]*programEntry
+}
+
+// programEntry is locked while the program is fetched so that concurrent
+// requests for the same program wait for a single fetch
+type programEntry struct {
+	mu        sync.Mutex
+	program   *Program
+	etag      string
+	fetchedAt time.Time
+}
+
+func newProgramCache() *programCache {
+	return &programCache{entries: make(map[string]*programEntry)}
+}
+
+// entry returns (and creates if needed) cache entry of the program
+func (pc *programCache) entry(company string, handle string) *programEntry {
+	if pc == nil {
+		return nil
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	key := strings.ToLower(company + "/" + handle)
+	e, ok := pc.entries[key]
+	if !ok {
+		e = &programEntry{}
+		pc.entries[key] = e
+	}
+	return e
+}
+
+func (c *Client) programTTL() time.Duration {
+	if c.ProgramTTL != 0 {
+		return c.ProgramTTL
+	}
+	return DefaultProgramTTL
+}
+
+// cached returns the cached program and its fetch time without fetching it
+func (pc *programCache) cached(company string, handle string) (*Program, time.Time) {
+	e := pc.entry(company, handle)
+	if e == nil {
+		return nil, time.Time{}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.program, e.fetchedAt
+}
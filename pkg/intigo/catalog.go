@@ -0,0 +1,373 @@
+package intitools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Synthetic activity types emitted by WatchCatalog. They are not used by the
+// activity feed.
+const (
+	ActivityNewProgram        = 1001
+	ActivityProgramInvitation = 1002
+)
+
+const defaultCatalogInterval = time.Hour
+
+// CatalogProgram is a program visible to the researcher
+type CatalogProgram struct {
+	ProgramId            string         `json:"programId"`
+	CompanyHandle        string         `json:"companyHandle"`
+	CompanyName          string         `json:"companyName"`
+	Handle               string         `json:"handle"`
+	Name                 string         `json:"name"`
+	Status               int            `json:"status"`
+	ConfidentialityLevel int            `json:"confidentialityLevel"`
+	MinBounty            ResponsePayout `json:"minBounty"`
+	MaxBounty            ResponsePayout `json:"maxBounty"`
+	LogoId               string         `json:"logoId"`
+	Joined               bool           `json:"joined"`               // Program terms were accepted
+	Invitation           bool           `json:"invitation,omitempty"` // Pending invitation
+	AssetTypes           []string       `json:"assetTypes,omitempty"` // Types of in-scope domains (new programs only)
+	FirstSeen            time.Time      `json:"firstSeen,omitempty"`
+}
+
+// Catalog is a local list of known programs and invitations kept in a JSON file
+type Catalog struct {
+	path     string
+	mu       sync.Mutex
+	programs map[string]CatalogProgram
+}
+
+type CatalogOptions struct {
+	Interval time.Duration // Polling interval (default 1h)
+	Catalog  *Catalog      // Known programs. Nothing is reported on the first poll of an empty catalog.
+	Buffer   int           // Size of the event channel buffer (default 16)
+}
+
+// key identifies the program in the catalog. An invitation has the key of
+// its program, so the program is not announced again once it is accepted.
+func (p CatalogProgram) key() string {
+	if p.ProgramId == "" {
+		return p.CompanyHandle + "/" + p.Handle
+	}
+	return p.ProgramId
+}
+
+// BountyRange returns e.g. "€100 - €5,000"
+func (p CatalogProgram) BountyRange() string {
+	if p.MaxBounty.Value == 0 {
+		return "No bounty"
+	}
+	return fmt.Sprintf("%s - %s", formatBounty(p.MinBounty), formatBounty(p.MaxBounty))
+}
+
+// catalogDetails returns e.g. "Acme · €100 - €5,000 · Public · URL, Android"
+func (c *Client) catalogDetails(p *CatalogProgram) string {
+	details := []string{p.BountyRange(), ConfidentialityLevelName(p.ConfidentialityLevel)}
+	if p.CompanyName != "" {
+		details = append([]string{p.CompanyName}, details...)
+	}
+	if len(p.AssetTypes) > 0 {
+		details = append(details, strings.Join(p.AssetTypes, ", "))
+	}
+	return strings.Join(details, " · ")
+}
+
+// Activity returns the synthetic activity announcing the program
+func (p CatalogProgram) Activity() Activity {
+	discriminator := ActivityNewProgram
+	if p.Invitation {
+		discriminator = ActivityProgramInvitation
+	}
+
+	seen := p.FirstSeen
+	if seen.IsZero() {
+		seen = time.Now()
+	}
+
+	return Activity{
+		Discriminator: discriminator,
+		Title:         p.Name,
+		CreatedAt:     seen.UnixNano() / int64(time.Millisecond),
+		Programid:     p.ProgramId,
+		Programlogoid: p.LogoId,
+		Programname:   p.Name,
+		Programhandle: p.Handle,
+		Companyhandle: p.CompanyHandle,
+	}
+}
+
+// GetPrograms returns programs listed for the researcher
+func (c *Client) GetPrograms(ctx context.Context) ([]CatalogProgram, error) {
+	return c.getCatalogList(ctx, fmt.Sprintf("%s/core/researcher/programs", c.ApiURL))
+}
+
+// GetJoinedPrograms returns programs the researcher has joined
+func (c *Client) GetJoinedPrograms(ctx context.Context) ([]CatalogProgram, error) {
+	programs, err := c.GetPrograms(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var joined []CatalogProgram
+	for _, p := range programs {
+		if p.Joined {
+			joined = append(joined, p)
+		}
+	}
+	return joined, nil
+}
+
+// GetInvitations returns programs with pending invitations
+func (c *Client) GetInvitations(ctx context.Context) ([]CatalogProgram, error) {
+	programs, err := c.getCatalogList(ctx, fmt.Sprintf("%s/core/researcher/invitations", c.ApiURL))
+	for i := range programs {
+		programs[i].Invitation = true
+	}
+	return programs, err
+}
+
+func (c *Client) getCatalogList(ctx context.Context, apiURL string) ([]CatalogProgram, error) {
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+
+	var res []CatalogProgram
+
+	if err := c.sendRequest(req, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// OpenCatalog opens the catalog file (it is created on first update)
+func OpenCatalog(path string) (*Catalog, error) {
+	cat := &Catalog{path: path, programs: make(map[string]CatalogProgram)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cat, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var programs []CatalogProgram
+	if err := json.Unmarshal(data, &programs); err != nil {
+		return nil, fmt.Errorf("cannot parse catalog %s: %w", path, err)
+	}
+	for _, p := range programs {
+		cat.programs[p.key()] = p
+	}
+
+	return cat, nil
+}
+
+// Programs returns all known programs and invitations ordered by name
+func (cat *Catalog) Programs() []CatalogProgram {
+	cat.mu.Lock()
+	defer cat.mu.Unlock()
+
+	return cat.list()
+}
+
+func (cat *Catalog) list() []CatalogProgram {
+	programs := make([]CatalogProgram, 0, len(cat.programs))
+	for _, p := range cat.programs {
+		programs = append(programs, p)
+	}
+	sort.Slice(programs, func(i, j int) bool {
+		if programs[i].Name != programs[j].Name {
+			return programs[i].Name < programs[j].Name
+		}
+		return programs[i].key() < programs[j].key()
+	})
+	return programs
+}
+
+// Update stores the current programs and returns those not known before.
+// Programs which are no longer listed (e.g. declined invitations) are
+// removed. A program listed more than once is kept as its first entry, so
+// programs should precede invitations.
+func (cat *Catalog) Update(programs []CatalogProgram) ([]CatalogProgram, error) {
+	cat.mu.Lock()
+	defer cat.mu.Unlock()
+
+	now := time.Now().UTC()
+	current := make(map[string]CatalogProgram, len(programs))
+
+	var added []CatalogProgram
+	for _, p := range programs {
+		if _, ok := current[p.key()]; ok {
+			continue
+		}
+		if known, ok := cat.programs[p.key()]; ok {
+			p.FirstSeen = known.FirstSeen
+			p.AssetTypes = known.AssetTypes
+		} else {
+			p.FirstSeen = now
+			added = append(added, p)
+		}
+		current[p.key()] = p
+	}
+	cat.programs = current
+
+	if err := cat.save(); err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
+
+// SetAssetTypes stores asset types of a known program
+func (cat *Catalog) SetAssetTypes(p CatalogProgram, types []string) error {
+	cat.mu.Lock()
+	defer cat.mu.Unlock()
+
+	known, ok := cat.programs[p.key()]
+	if !ok {
+		return nil
+	}
+	known.AssetTypes = types
+	cat.programs[p.key()] = known
+
+	return cat.save()
+}
+
+// save writes the catalog to a temporary file replacing the catalog file,
+// so it is never left half written. Callers must hold cat.mu.
+func (cat *Catalog) save() error {
+	data, err := json.MarshalIndent(cat.list(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(cat.path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(cat.path+".tmp", cat.path)
+}
+
+// programAssetTypes returns distinct types of the latest in-scope domains
+func programAssetTypes(p *Program) []string {
+	var types []string
+	seen := make(map[int]bool)
+
+	for _, d := range latestDomains(p.Domains) {
+		if seen[d.Type] || d.BountyTierId == 5 { // Out of scope
+			continue
+		}
+		seen[d.Type] = true
+		types = append(types, lookupName(endpointTypes, d.Type))
+	}
+	sort.Strings(types)
+
+	return types
+}
+
+// WatchCatalog periodically lists programs and invitations and emits
+// synthetic events (ActivityNewProgram, ActivityProgramInvitation) for those
+// missing in the catalog. Errors are reported as events with Err set. The
+// channel is closed when ctx is cancelled.
+func (c *Client) WatchCatalog(ctx context.Context, opts CatalogOptions) (<-chan ActivityEvent, error) {
+	if opts.Catalog == nil || opts.Interval < 0 || opts.Buffer < 0 {
+		return nil, fmt.Errorf("invalid catalog options")
+	}
+	if opts.Interval == 0 {
+		opts.Interval = defaultCatalogInterval
+	}
+	if opts.Buffer == 0 {
+		opts.Buffer = defaultWatchBuffer
+	}
+
+	out := make(chan ActivityEvent, opts.Buffer)
+
+	go func() {
+		defer close(out)
+
+		// Without any known programs everything would be new
+		seed := len(opts.Catalog.Programs()) == 0
+
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+
+			events, err := c.pollCatalog(ctx, opts.Catalog, seed)
+			if err == nil {
+				seed = false
+			} else {
+				events = append(events, ActivityEvent{Err: fmt.Errorf("Catalog error: %w", err)})
+			}
+
+			for _, e := range events {
+				select {
+				case out <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			timer.Reset(opts.Interval)
+		}
+	}()
+
+	return out, nil
+}
+
+func (c *Client) pollCatalog(ctx context.Context, cat *Catalog, seed bool) ([]ActivityEvent, error) {
+	// Session is kept alive by Watch (or authenticated on first request)
+	programs, err := c.GetPrograms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	invitations, err := c.GetInvitations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	added, err := cat.Update(append(programs, invitations...))
+	if err != nil || seed {
+		return nil, err
+	}
+
+	receivedAt := time.Now()
+	events := make([]ActivityEvent, 0, len(added))
+	for i := range added {
+		p := added[i]
+		if details, err := c.GetProgram(ctx, p.CompanyHandle, p.Handle); err == nil {
+			p.AssetTypes = programAssetTypes(details)
+			if err := cat.SetAssetTypes(p, p.AssetTypes); err != nil {
+				log.Printf("Cannot save catalog: %s\n", err)
+			}
+		} else {
+			log.Printf("Cannot fetch program %s/%s: %s\n", p.CompanyHandle, p.Handle, err)
+		}
+
+		events = append(events, ActivityEvent{
+			Activity:   p.Activity(),
+			Known:      true,
+			ReceivedAt: receivedAt,
+			Catalog:    &p,
+		})
+	}
+
+	return events, nil
+}
@@ -0,0 +1,444 @@
+package intitools
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+)
+
+// ProgramDiff is a change of program content between two versions. It is
+// rendered by every sink on its own (see Slack, Markdown, HTML and String);
+// marshalled to JSON it keeps the full structure.
+type ProgramDiff struct {
+	Section string                  `json:"section"`        // Changed content, e.g. "InScopes" or "Domains"
+	From    time.Time               `json:"from,omitempty"` // Time of the previous version (zero for the first version)
+	To      time.Time               `json:"to"`             // Time of the new version
+	Added   []ProgramDomainsContent `json:"added,omitempty"`
+	Removed []ProgramDomainsContent `json:"removed,omitempty"`
+	Changed []DomainChange          `json:"changed,omitempty"`
+	Fields  []FieldChange           `json:"fields,omitempty"`
+	Hunks   []DiffHunk              `json:"hunks,omitempty"` // Changes of text content
+	Mode    string                  `json:"mode,omitempty"`  // Diff mode of text content (see DiffModes)
+
+	First       bool   `json:"first,omitempty"`       // There is no previous version, everything is new
+	Unavailable string `json:"unavailable,omitempty"` // Why the change cannot be shown (no changes are set)
+}
+
+// DomainChange is a domain (endpoint) present in both versions with different details
+type DomainChange struct {
+	Old   ProgramDomainsContent `json:"old"`
+	New   ProgramDomainsContent `json:"new"`
+	Hunks []DiffHunk            `json:"hunks,omitempty"` // Changes of the description
+}
+
+// DiffHunk is a block of changed lines with some context
+type DiffHunk struct {
+	FromLine   int        `json:"fromLine"`
+	ToLine     int        `json:"toLine"`
+	Lines      []DiffLine `json:"lines"`
+	Paragraphs bool       `json:"paragraphs,omitempty"` // Lines are paragraphs compared word by word (or sentence by sentence)
+}
+
+type DiffLine struct {
+	Kind     string        `json:"kind"` // "+" inserted, "-" deleted, " " unchanged, "~" changed paragraph (see Segments)
+	Text     string        `json:"text"`
+	Segments []DiffSegment `json:"segments,omitempty"` // Changed words of "~" lines
+}
+
+// textHunks returns hunks of changes between old and new text. Changes of
+// whitespace and markdown formatting only are ignored. The first version is
+// always compared line by line.
+func textHunks(old string, new string, mode string) []DiffHunk {
+	if old == new || sameProse(old, new) {
+		return nil
+	}
+	if mode != DiffLines && old != "" {
+		return proseHunks(old, new, mode)
+	}
+
+	// gotextdiff expects text ending with a newline
+	if old != "" && !strings.HasSuffix(old, "\n") {
+		old += "\n"
+	}
+	if new != "" && !strings.HasSuffix(new, "\n") {
+		new += "\n"
+	}
+
+	edits := myers.ComputeEdits(span.URIFromPath("old"), old, new)
+	unified := gotextdiff.ToUnified("old", "new", old, edits)
+
+	hunks := make([]DiffHunk, 0, len(unified.Hunks))
+	for _, h := range unified.Hunks {
+		hunk := DiffHunk{FromLine: h.FromLine, ToLine: h.ToLine}
+		for _, l := range h.Lines {
+			kind := " "
+			switch l.Kind {
+			case gotextdiff.Insert:
+				kind = "+"
+			case gotextdiff.Delete:
+				kind = "-"
+			}
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: kind, Text: strings.TrimSuffix(l.Content, "\n")})
+		}
+		hunks = append(hunks, hunk)
+	}
+
+	return hunks
+}
+
+// newTextDiff returns diff of a text section
+func newTextDiff(section string, mode string, from time.Time, to time.Time, old string, new string) *ProgramDiff {
+	return &ProgramDiff{
+		Section: section,
+		From:    from,
+		To:      to,
+		Hunks:   textHunks(old, new, mode),
+		Mode:    mode,
+	}
+}
+
+// newDomainsDiff returns diff of two domain lists matched by domain id
+func newDomainsDiff(mode string, from time.Time, to time.Time, old []ProgramDomainsContent, new []ProgramDomainsContent) *ProgramDiff {
+	d := &ProgramDiff{Section: "Domains", From: from, To: to, Mode: mode}
+
+	newById := make(map[string]ProgramDomainsContent, len(new))
+	for _, n := range new {
+		newById[n.Id] = n
+	}
+	oldById := make(map[string]ProgramDomainsContent, len(old))
+	for _, o := range old {
+		oldById[o.Id] = o
+		if _, ok := newById[o.Id]; !ok {
+			d.Removed = append(d.Removed, o)
+		}
+	}
+
+	for _, n := range new {
+		o, ok := oldById[n.Id]
+		if !ok {
+			d.Added = append(d.Added, n)
+			continue
+		}
+		if o != n {
+			d.Changed = append(d.Changed, DomainChange{
+				Old:   o,
+				New:   n,
+				Hunks: textHunks(o.Description, n.Description, mode),
+			})
+		}
+	}
+
+	return d
+}
+
+// Empty reports whether the diff contains no changes
+func (d *ProgramDiff) Empty() bool {
+	return d == nil || len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 &&
+		len(d.Fields) == 0 && len(d.Hunks) == 0
+}
+
+// NewAssets returns parsed assets of added domains and of updated domains
+// with a new endpoint (assets already listed before are skipped)
+func (d *ProgramDiff) NewAssets() []Asset {
+	if d == nil {
+		return nil
+	}
+
+	var assets []Asset
+	for _, dom := range d.Added {
+		assets = append(assets, ParseDomain(dom)...)
+	}
+	for _, chg := range d.Changed {
+		if chg.Old.Endpoint == chg.New.Endpoint {
+			continue
+		}
+		old := make(map[string]bool)
+		for _, a := range ParseDomain(chg.Old) {
+			old[a.Key()] = true
+		}
+		for _, a := range ParseDomain(chg.New) {
+			if !old[a.Key()] {
+				assets = append(assets, a)
+			}
+		}
+	}
+
+	return DedupAssets(assets)
+}
+
+// diffStyle defines how a sink renders diffs
+type diffStyle struct {
+	escape     func(string) string // Escapes plain text
+	code       func(string) string // Inline code (text is escaped)
+	bold       func(string) string
+	openBlock  string                         // Starts block of diff lines
+	closeBlock string                         // Ends block of diff lines
+	line       func(kind, text string) string // Diff line inside a block (text is not escaped)
+	deleted    func(string) string            // Deleted words (text is escaped)
+	inserted   func(string) string            // Inserted words (text is escaped)
+}
+
+func plainCode(s string) string { return "`" + strings.Replace(s, "`", "'", -1) + "`" }
+
+var plainDiffStyle = diffStyle{
+	escape:   func(s string) string { return s },
+	code:     func(s string) string { return s },
+	bold:     func(s string) string { return s },
+	line:     func(kind, text string) string { return kind + text },
+	deleted:  func(s string) string { return "[-" + s + "-]" },
+	inserted: func(s string) string { return "{+" + s + "+}" },
+}
+
+var slackDiffStyle = diffStyle{
+	escape:     slackEscaper.Replace,
+	code:       plainCode,
+	bold:       func(s string) string { return "*" + s + "*" },
+	openBlock:  "```\n",
+	closeBlock: "```\n",
+	line: func(kind, text string) string {
+		return kind + slackEscaper.Replace(strings.Replace(text, "```", "'''", -1))
+	},
+	deleted:  func(s string) string { return "~" + s + "~" },
+	inserted: func(s string) string { return "*" + s + "*" },
+}
+
+var markdownDiffStyle = diffStyle{
+	escape:     func(s string) string { return s },
+	code:       plainCode,
+	bold:       func(s string) string { return "**" + s + "**" },
+	openBlock:  "```diff\n",
+	closeBlock: "```\n",
+	line: func(kind, text string) string {
+		return kind + strings.Replace(text, "```", "'''", -1)
+	},
+	deleted:  func(s string) string { return "~~" + s + "~~" },
+	inserted: func(s string) string { return "**" + s + "**" },
+}
+
+var htmlDiffStyle = diffStyle{
+	escape:     html.EscapeString,
+	code:       func(s string) string { return "<code>" + s + "</code>" },
+	bold:       func(s string) string { return "<b>" + s + "</b>" },
+	openBlock:  "<pre>\n",
+	closeBlock: "</pre>\n",
+	line: func(kind, text string) string {
+		switch kind {
+		case "+":
+			return "<ins>+" + html.EscapeString(text) + "</ins>"
+		case "-":
+			return "<del>-" + html.EscapeString(text) + "</del>"
+		}
+		return " " + html.EscapeString(text)
+	},
+	deleted:  func(s string) string { return "<del>" + s + "</del>" },
+	inserted: func(s string) string { return "<ins>" + s + "</ins>" },
+}
+
+// diffTime formats version times of diffs rendered without a client, i.e.
+// with the defaults of Client.FormatTime. Use the Client.Diff* methods to
+// respect the configured display time zone and format.
+func diffTime(t time.Time) string {
+	var c Client
+	return c.FormatTime(t)
+}
+
+// String renders the diff as plain text
+func (d *ProgramDiff) String() string {
+	return d.render(plainDiffStyle, 0, diffTime)
+}
+
+// Slack renders the diff as Slack mrkdwn of at most max bytes (0 for no limit)
+func (d *ProgramDiff) Slack(max int) string {
+	return d.render(slackDiffStyle, max, diffTime)
+}
+
+// Markdown renders the diff as Discord flavoured markdown of at most max
+// bytes (0 for no limit)
+func (d *ProgramDiff) Markdown(max int) string {
+	return d.render(markdownDiffStyle, max, diffTime)
+}
+
+// HTML renders the diff as an HTML fragment
+func (d *ProgramDiff) HTML() string {
+	return d.render(htmlDiffStyle, 0, diffTime)
+}
+
+// DiffString renders the diff as plain text with version times in the
+// display time zone and format
+func (c *Client) DiffString(d *ProgramDiff) string {
+	return d.render(plainDiffStyle, 0, c.FormatTime)
+}
+
+// DiffSlack is ProgramDiff.Slack with version times in the display time zone
+// and format
+func (c *Client) DiffSlack(d *ProgramDiff, max int) string {
+	return d.render(slackDiffStyle, max, c.FormatTime)
+}
+
+// DiffMarkdown is ProgramDiff.Markdown with version times in the display time
+// zone and format
+func (c *Client) DiffMarkdown(d *ProgramDiff, max int) string {
+	return d.render(markdownDiffStyle, max, c.FormatTime)
+}
+
+// diffWriter collects rendered lines until the size limit is reached
+type diffWriter struct {
+	st      diffStyle
+	max     int
+	b       strings.Builder
+	inBlock bool
+	omitted int
+}
+
+// diffReserve is room kept for closing a block and the truncation note
+const diffReserve = 64
+
+func (w *diffWriter) write(s string, block bool) {
+	if w.omitted > 0 {
+		w.omitted++
+		return
+	}
+
+	extra := ""
+	if block && !w.inBlock {
+		extra = w.st.openBlock
+	} else if !block && w.inBlock {
+		extra = w.st.closeBlock
+	}
+
+	if w.max > 0 && w.b.Len()+len(extra)+len(s)+1+diffReserve > w.max {
+		w.omitted++
+		return
+	}
+
+	w.b.WriteString(extra)
+	w.inBlock = block
+	w.b.WriteString(s)
+	w.b.WriteString("\n")
+}
+
+func (w *diffWriter) hunks(hunks []DiffHunk) {
+	for _, h := range hunks {
+		if h.Paragraphs {
+			w.proseHunk(h)
+			continue
+		}
+		w.write(fmt.Sprintf("@@ -%d +%d @@", h.FromLine, h.ToLine), true)
+		for _, l := range h.Lines {
+			w.write(w.st.line(l.Kind, l.Text), true)
+		}
+	}
+}
+
+// proseHunk writes changed paragraphs as text with changed words highlighted
+func (w *diffWriter) proseHunk(h DiffHunk) {
+	st := w.st
+	w.write(st.bold(st.escape(fmt.Sprintf("Paragraph %d:", h.ToLine))), false)
+
+	for _, l := range h.Lines {
+		switch l.Kind {
+		case "-":
+			w.write(st.deleted(st.escape(l.Text)), false)
+		case "+":
+			w.write(st.inserted(st.escape(l.Text)), false)
+		default:
+			parts := make([]string, 0, len(l.Segments))
+			for i, seg := range l.Segments {
+				switch seg.Kind {
+				case "-":
+					parts = append(parts, st.deleted(st.escape(seg.Text)))
+				case "+":
+					parts = append(parts, st.inserted(st.escape(seg.Text)))
+				default:
+					parts = append(parts, st.escape(elide(seg.Text, i == 0, i == len(l.Segments)-1)))
+				}
+			}
+			w.write(strings.Join(parts, " "), false)
+		}
+	}
+}
+
+func (w *diffWriter) String() string {
+	if w.inBlock {
+		w.b.WriteString(w.st.closeBlock)
+	}
+	if w.omitted > 0 {
+		w.b.WriteString(w.st.escape(fmt.Sprintf("[...] %d more lines", w.omitted)))
+	}
+	return strings.TrimSuffix(w.b.String(), "\n")
+}
+
+// versions returns the "from → to" header of the diff (empty without times)
+func (d *ProgramDiff) versions(formatTime func(time.Time) string) string {
+	switch {
+	case d.To.IsZero():
+		return ""
+	case d.From.IsZero():
+		return formatTime(d.To)
+	}
+	return formatTime(d.From) + " → " + formatTime(d.To)
+}
+
+func (d *ProgramDiff) render(st diffStyle, max int, formatTime func(time.Time) string) string {
+	if d == nil {
+		return st.escape("Diff unavailable")
+	}
+	if d.Unavailable != "" {
+		return st.escape("History unavailable: " + d.Unavailable)
+	}
+	if d.Empty() {
+		return st.escape("No changes")
+	}
+
+	w := &diffWriter{st: st, max: max}
+	header := d.versions(formatTime)
+	switch {
+	case d.First && header != "":
+		w.write(st.escape("First version ("+header+"):"), false)
+	case d.First:
+		w.write(st.escape("First version:"), false)
+	case header != "":
+		w.write(st.escape(header), false)
+	}
+	domain := func(dom ProgramDomainsContent) string {
+		kinds := append([]string{lookupName(endpointTypes, dom.Type)}, AssetKinds(ParseDomain(dom))...)
+		return fmt.Sprintf("%s (%s)", st.code(st.escape(dom.Endpoint)), st.escape(strings.Join(kinds, ", ")))
+	}
+
+	for _, fc := range d.Fields {
+		w.write(fmt.Sprintf("%s: %s → %s", st.bold(st.escape(fc.Field)), st.escape(fc.Old), st.escape(fc.New)), false)
+	}
+
+	for _, dom := range d.Removed {
+		w.write(fmt.Sprintf("%s was removed!", domain(dom)), false)
+	}
+	for _, dom := range d.Added {
+		w.write(fmt.Sprintf("%s was added within %s!", domain(dom), st.escape(lookupName(endpointTiers, dom.BountyTierId))), false)
+	}
+	for _, chg := range d.Changed {
+		o, n := chg.Old, chg.New
+		w.write(fmt.Sprintf("%s was updated:", domain(n)), false)
+		if o.Endpoint != n.Endpoint {
+			w.write(fmt.Sprintf(" - Endpoint: %s → %s", st.code(st.escape(o.Endpoint)), st.code(st.escape(n.Endpoint))), false)
+		}
+		if o.Type != n.Type {
+			w.write(fmt.Sprintf(" - Type: %s → %s", st.escape(lookupName(endpointTypes, o.Type)), st.escape(lookupName(endpointTypes, n.Type))), false)
+		}
+		if o.BountyTierId != n.BountyTierId {
+			w.write(fmt.Sprintf(" - Tier: %s → %s", st.escape(lookupName(endpointTiers, o.BountyTierId)), st.escape(lookupName(endpointTiers, n.BountyTierId))), false)
+		}
+		if len(chg.Hunks) > 0 {
+			w.write(" - Description:", false)
+			w.hunks(chg.Hunks)
+		}
+	}
+
+	w.hunks(d.Hunks)
+
+	return w.String()
+}
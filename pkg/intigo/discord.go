@@ -7,20 +7,35 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
-	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	discordDiffLength      = 3500 // Rendered diffs (with scope conflicts) fit in an embed description (4096 characters)
+	discordConflictsLength = 1500 // Scope conflicts, counted in discordDiffLength
+	discordExcerptLength   = 1800 // Quoted text of descriptions and program updates
 )
 
 type discordMessage struct {
 	Embeds []discordMsgEmbeds `json:"embeds"`
 }
 
+// DiscordFile is a text file attached to a message
+type DiscordFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
 type discordMsgEmbeds struct {
 	Color       int          `json:"color"`
 	Title       string       `json:"title"`
 	URL         string       `json:"url"`
 	Description string       `json:"description"`
 	Thumbnail   discordThumb `json:"thumbnail"`
+	Timestamp   string       `json:"timestamp,omitempty"`
 }
 
 type discordThumb struct {
@@ -28,20 +43,32 @@ type discordThumb struct {
 }
 
 func (c *Client) DiscordSend(ctx context.Context, message string) error {
-	webhookURL := c.WebhookURL
+	return c.DiscordSendTo(ctx, c.WebhookURL, message)
+}
+
+// DiscordSendTo sends message to the given Discord webhook
+func (c *Client) DiscordSendTo(ctx context.Context, webhookURL string, message string) error {
+	return c.DiscordSendFiles(ctx, webhookURL, message, nil)
+}
 
+// DiscordSendFiles sends message with the files attached to the given
+// Discord webhook
+func (c *Client) DiscordSendFiles(ctx context.Context, webhookURL string, message string, files []DiscordFile) error {
 	if webhookURL == "" {
 		return fmt.Errorf("Webhook not defined.")
 	}
 
-	jsonStr := []byte(message)
+	body, contentType, err := discordBody(message, files)
+	if err != nil {
+		return err
+	}
 
-	req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(jsonStr))
+	req, err := http.NewRequest("POST", webhookURL, body)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 
 	err = c.Ratelimiter.Wait(ctx) // This is a blocking call. Honors the rate limit
 	if err != nil {
@@ -67,22 +94,63 @@ func (c *Client) DiscordSend(ctx context.Context, message string) error {
 	return nil
 }
 
+// discordBody returns the request body of the message: the JSON payload, or
+// a multipart form with the payload and attached files
+func discordBody(message string, files []DiscordFile) (io.Reader, string, error) {
+	if len(files) == 0 {
+		return strings.NewReader(message), "application/json", nil
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if err := w.WriteField("payload_json", message); err != nil {
+		return nil, "", err
+	}
+	for i, f := range files {
+		part, err := w.CreateFormFile(fmt.Sprintf("files[%d]", i), f.Name)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := io.WriteString(part, f.Content); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return &body, w.FormDataContentType(), nil
+}
+
 func (c *Client) DiscordFormatActivity(a Activity) (string, error) {
+	return c.DiscordFormatEvent(ActivityEvent{Activity: a})
+}
+
+// DiscordFormatEvent formats activity event using details added by enrichment
+func (c *Client) DiscordFormatEvent(e ActivityEvent) (string, error) {
+	message, _, err := c.DiscordFormatEventFull(e)
+	return message, err
+}
+
+// DiscordFormatEventFull formats activity event like DiscordFormatEvent.
+// Content shortened in the message (long descriptions, diffs and program
+// updates) is returned in full as files to attach to it.
+func (c *Client) DiscordFormatEventFull(e ActivityEvent) (string, []DiscordFile, error) {
 
+	a := e.Activity
 	var message string
 
-	submissionLink := fmt.Sprintf("https://app.intigriti.com/researcher/submissions/%s/%s",
-		url.PathEscape(a.Programid), url.PathEscape(a.Submissioncode))
+	submissionLink := c.submissionURL(a.Programid, a.Submissioncode)
 	submissionTitle := fmt.Sprintf("[%s] %s", a.Programname, a.Submissiontitle)
 
-	programLink := fmt.Sprintf("https://app.intigriti.com/researcher/programs/%s/%s/detail",
-		url.PathEscape(a.Companyhandle), url.PathEscape(a.Programhandle))
+	programLink := c.programURL(a.Companyhandle, a.Programhandle)
 	programTitle := a.Programname
 
-	iconUrl := fmt.Sprintf("https://app.intigriti.com/api/file/api/file/%s", a.Programlogoid)
+	iconUrl := fmt.Sprintf("%s/file/api/file/%s", c.ApiURL, a.Programlogoid)
 
 	var link string
 	var title string
+	var files []DiscordFile // Complete content attached when the message shows a part only
 
 	switch d := a.Discriminator; d {
 
@@ -90,46 +158,66 @@ func (c *Client) DiscordFormatActivity(a Activity) (string, error) {
 		userRole := a.User.Role
 		// Do not send notifications about our own messages
 		if userRole == "RESEARCHER" {
-			return "", fmt.Errorf("empty message")
+			return "", nil, ErrEmptyMessage
 		}
 
 		message = fmt.Sprintf("New **message** from *%s* (%s)",
 			a.User.Username, userRole)
+		text, attachments := messageExcerpt(e.Message)
+		if text != "" {
+			message += "\n" + discordQuote(text)
+		}
+		if attachments != "" {
+			message += "\n:paperclip: " + strings.Replace(attachments, "@", "@\u200b", -1)
+		}
 		link = submissionLink
 		title = submissionTitle
 
 	//	2	Submission 	- Status change
 	case 2:
-		newState := c.GetSubmissionState(a.Newstate.Status)
-		// If status is Closed add reason
-		if a.Newstate.Status == 4 {
-			newState += " as " + c.GetClosedState(a.Newstate.Closereason)
+		// Closed status includes the reason
+		t := a.Transition()
+		if t.From != "" {
+			message = fmt.Sprintf("The **status** changed `%s` → `%s`", t.From, t.To)
+		} else {
+			message = fmt.Sprintf("The **status** changed to `%s`", t.To)
+		}
+		if d := e.Duplicate; d != nil {
+			message += fmt.Sprintf("\nDuplicate of [%s](%s)%s", d.Code, c.submissionURL(d.ProgramId, d.Code), c.duplicateDetails(d))
 		}
-
-		message = fmt.Sprintf("The **status** changed to `%s`", newState)
 		link = submissionLink
 		title = submissionTitle
 
 	//	3	Submission 	- Change Severity
 	case 3:
-		message = fmt.Sprintf("The **severity** changed to `%s`", c.GetSeverity(a.Newseverityid))
+		if e.PreviousSeverity > 0 {
+			message = fmt.Sprintf("The **severity** changed `%s` → `%s`", c.GetSeverity(e.PreviousSeverity), c.GetSeverity(a.Newseverityid))
+		} else {
+			message = fmt.Sprintf("The **severity** changed to `%s`", c.GetSeverity(a.Newseverityid))
+		}
 		link = submissionLink
 		title = submissionTitle
 
 	//	5 	Submission 	- Payout
 	case 5:
-		message = fmt.Sprintf("New payout **%s %.f** :partying_face:", a.NewPayoutAmount.Currency, a.NewPayoutAmount.Value)
+		message = fmt.Sprintf("New payout **%s** :partying_face:", c.payoutAmount(e))
 		link = submissionLink
 		title = submissionTitle
 
 	//	7 	Submission 	- Change vulnerable endpoint
 	case 7:
 		message = fmt.Sprintf("The **endpoint / vulnerable component** changed")
+		if a.Newendpoint != "" {
+			message += fmt.Sprintf(" to `%s`", truncate(a.Newendpoint, 200))
+		}
 		link = submissionLink
 		title = submissionTitle
 	//	8 	Submission 	- User changed vulnerability type
 	case 8:
 		message = fmt.Sprintf("**@%s** changed vulnerability **type**", a.UserName)
+		if e.Submission != nil && e.Submission.Type.Name != "" {
+			message += fmt.Sprintf(" to `%s`", e.Submission.Type.Name)
+		}
 		link = submissionLink
 		title = submissionTitle
 
@@ -152,74 +240,108 @@ func (c *Client) DiscordFormatActivity(a Activity) (string, error) {
 
 	//	20 	Program		- Status Change
 	case 20:
-		message = fmt.Sprintf("Program changed **status** to `%s`", c.GetProgramState(a.Newstatusid))
+		t := a.Transition()
+		if t.From != "" {
+			message = fmt.Sprintf("Program changed **status** `%s` → `%s`", t.From, t.To)
+		} else {
+			message = fmt.Sprintf("Program changed **status** to `%s`", t.To)
+		}
 		link = programLink
 		title = programTitle
 	//	22 	Program		- Change description
 	case 22:
-		descr := a.Description
-		if len(descr) > 1800 {
-			descr = descr[:1800]
+		diff := c.eventDiff(e)
+		if diff == nil || diff.Unavailable != "" {
+			message = fmt.Sprintf("Program changed **description** (%s)\n%s", c.DiffMarkdown(diff, 0), discordCodeBlock(a.Description, discordExcerptLength))
+			if len(a.Description) > discordExcerptLength {
+				files = append(files, DiscordFile{Name: "description.md", Content: a.Description})
+			}
+		} else {
+			message = fmt.Sprintf("Program changed **description**\n%s", c.DiffMarkdown(diff, discordDiffLength))
+			if len(c.DiffMarkdown(diff, 0)) > discordDiffLength {
+				files = append(files, DiscordFile{Name: "description.diff", Content: c.DiffString(diff)})
+			}
 		}
-		message = fmt.Sprintf("Program changed description: \n```\n%s```", descr)
 		link = programLink
 		title = programTitle
 	//	23 	Program		- Update bounties
 	case 23:
-		message = fmt.Sprintf("Program updated **bounties**")
+		diff := c.eventDiff(e)
+		message = fmt.Sprintf("Program updated **bounties**\n%s", c.DiffMarkdown(diff, discordDiffLength))
 		link = programLink
 		title = programTitle
 	//	24 	Program		- Update in scope
 	case 24:
-		diff := c.GetProgramContentDiff(a, "InScopes")
-		message = fmt.Sprintf("Program updated **in scope**\n```diff\n%s\n```", diff)
+		diff := c.eventDiff(e)
+		message = fmt.Sprintf("Program updated **in scope**\n%s", c.markdownScopeDiff(e, diff))
 		link = programLink
 		title = programTitle
 	//	25 	Program		- Update out of scope
 	case 25:
-		diff := c.GetProgramContentDiff(a, "OutScopes")
-		message = fmt.Sprintf("Program updated **out of scope**\n```diff\n%s\n```", diff)
+		diff := c.eventDiff(e)
+		message = fmt.Sprintf("Program updated **out of scope**\n%s", c.markdownScopeDiff(e, diff))
 		link = programLink
 		title = programTitle
 	//	26 	Program		- Update FAQ
 	case 26:
-		diff := c.GetProgramContentDiff(a, "Faqs")
-		message = fmt.Sprintf("Program updated **FAQ**\n```diff\n%s\n```", diff)
+		diff := c.eventDiff(e)
+		message = fmt.Sprintf("Program updated **FAQ**\n%s", c.DiffMarkdown(diff, discordDiffLength))
 		link = programLink
 		title = programTitle
 	//	27 	Program		- Update domains
 	case 27:
-		diff := c.GetProgramDomainsDiff(a)
-		//message = fmt.Sprintf("Program updated **domains**```")
-		message = fmt.Sprintf("Program updated **domains**\n\n%s", diff)
+		diff := c.eventDiff(e)
+		message = fmt.Sprintf("Program updated **domains**\n\n%s", c.markdownScopeDiff(e, diff))
 		link = programLink
 		title = programTitle
 	//	28 	Program		- Update rules of engagement
 	case 28:
-		diff := c.GetProgramRulesDiff(a)
-		message = fmt.Sprintf("Program updated **rules of engagement**\n```diff\n%s\n```", diff)
+		diff := c.eventDiff(e)
+		message = fmt.Sprintf("Program updated **rules of engagement**\n%s", c.DiffMarkdown(diff, discordDiffLength))
 		link = programLink
 		title = programTitle
 	//	29 	Program		- Update severity assessment
 	case 29:
-		diff := c.GetProgramContentDiff(a, "SeverityAssessments")
-		message = fmt.Sprintf("Program updated **severity assessment**\n```diff\n%s\n```", diff)
+		diff := c.eventDiff(e)
+		message = fmt.Sprintf("Program updated **severity assessment**\n%s", c.DiffMarkdown(diff, discordDiffLength))
 		link = programLink
 		title = programTitle
 		//	47 	Program		- Program update published
 	case 47:
-		descr := a.Description
-		if len(descr) > 250 {
-			descr = descr[:250]
+		// Text of the activity, attached in full when it is long
+		message = fmt.Sprintf("Program published an update: **%s**\n%s", a.Title, discordCodeBlock(a.Description, discordExcerptLength))
+		if len(a.Description) > discordExcerptLength {
+			files = append(files, DiscordFile{Name: "update.md", Content: a.Description})
+		}
+		link = programLink
+		title = programTitle
+
+	case ActivityNewProgram, ActivityProgramInvitation:
+		if e.Catalog == nil {
+			break
 		}
-		message = fmt.Sprintf("Program published an update: **%s**\n```%s```", a.Title, descr)
+		if d == ActivityProgramInvitation {
+			message = "**Invitation** to a private program"
+		} else {
+			message = "**New program** published"
+		}
+		message += "\n" + c.catalogDetails(e.Catalog)
+		link = programLink
+		title = programTitle
+
+	case ActivityProgramMetadata:
+		message = fmt.Sprintf("Program %s", a.Title)
 		link = programLink
 		title = programTitle
 
 	}
 
 	if message == "" {
-		message = fmt.Sprintf("Unknown message type: %d", a.Discriminator)
+		message = c.unknownActivityMessage(a, discordCodeBlock)
+	}
+
+	if details := c.submissionDetails(e.Submission); details != "" {
+		message += fmt.Sprintf("\n*%s*", details)
 	}
 
 	embedMsg := discordMsgEmbeds{
@@ -231,6 +353,11 @@ func (c *Client) DiscordFormatActivity(a Activity) (string, error) {
 		},
 	}
 
+	// Time of the activity (not of delivery)
+	if a.CreatedAt > 0 {
+		embedMsg.Timestamp = a.Time().In(c.location()).Format(time.RFC3339)
+	}
+
 	embed := make([]discordMsgEmbeds, 0)
 	embed = append(embed, embedMsg)
 	discordMsg := discordMessage{
@@ -240,9 +367,21 @@ func (c *Client) DiscordFormatActivity(a Activity) (string, error) {
 	jsonMsg, err := json.Marshal(discordMsg)
 
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	return string(jsonMsg), nil
+	return string(jsonMsg), files, nil
+
+}
+
+// discordCodeBlock returns text shortened to n bytes as a code block
+func discordCodeBlock(text string, n int) string {
+	return "```\n" + strings.Replace(truncate(text, n), "```", "'''", -1) + "```"
+}
 
+// markdownScopeDiff renders the diff of a scope event followed by scope
+// conflicts, together at most discordDiffLength bytes
+func (c *Client) markdownScopeDiff(e ActivityEvent, diff *ProgramDiff) string {
+	conflicts := c.eventConflicts(e).render(markdownDiffStyle, discordConflictsLength)
+	return c.DiffMarkdown(diff, discordDiffLength-len(conflicts)) + conflicts
 }
@@ -0,0 +1,269 @@
+package intitools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	FilterInclude = "include"
+	FilterExclude = "exclude"
+)
+
+// Filter decides which activities are delivered. Rules are evaluated in order
+// and the first matching rule wins. Activities not matching any rule are
+// handled according to Default.
+type Filter struct {
+	Default string       `json:"default"` // "include" (default) or "exclude"
+	Rules   []FilterRule `json:"rules"`
+}
+
+// FilterRule matches an activity if all of its non-empty conditions match.
+// Conditions on severity, closed reason, status transition and payout only
+// match activities carrying that information (severity changes, status
+// changes and payouts). AssetKinds only matches scope events with a diff
+// adding assets of one of the kinds (see ParseAsset), e.g. ["wildcard"].
+type FilterRule struct {
+	Name           string   `json:"name"`
+	Action         string   `json:"action"` // "include" or "exclude"
+	Programs       []string `json:"programs"`
+	Companies      []string `json:"companies"`
+	Discriminators []int    `json:"discriminators"`
+	Severities     []string `json:"severities"`
+	ClosedReasons  []string `json:"closedReasons"`
+	FromStatus     []string `json:"fromStatus"` // Previous submission / program status
+	ToStatus       []string `json:"toStatus"`   // New status, e.g. "Closed" or "Closed as Duplicate"
+	Currencies     []string `json:"currencies"`
+	MinPayout      *float64 `json:"minPayout"`
+	MaxPayout      *float64 `json:"maxPayout"`
+	Title          string   `json:"title"`       // Case-insensitive regexp matched against title
+	Description    string   `json:"description"` // Case-insensitive regexp matched against description
+	AssetKinds     []string `json:"assetKinds"`  // Kinds of added assets, e.g. "wildcard" or "iprange"
+
+	title       *regexp.Regexp
+	description *regexp.Regexp
+}
+
+// LoadFilter reads filter rules from a JSON file
+func LoadFilter(path string) (*Filter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Filter{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("cannot parse filter %s: %w", path, err)
+	}
+
+	if err := f.Compile(); err != nil {
+		return nil, fmt.Errorf("invalid filter %s: %w", path, err)
+	}
+
+	return f, nil
+}
+
+// Compile validates the filter and prepares text matchers.
+// It must be called before Allow if the filter was not created by LoadFilter.
+func (f *Filter) Compile() error {
+	if f.Default == "" {
+		f.Default = FilterInclude
+	}
+	if f.Default != FilterInclude && f.Default != FilterExclude {
+		return fmt.Errorf("unknown default action %q", f.Default)
+	}
+
+	for i := range f.Rules {
+		r := &f.Rules[i]
+
+		if r.Action != FilterInclude && r.Action != FilterExclude {
+			return fmt.Errorf("rule %d: unknown action %q", i+1, r.Action)
+		}
+
+		var err error
+		if r.title, err = compileMatch(r.Title); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		if r.description, err = compileMatch(r.Description); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func compileMatch(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile("(?i)" + expr)
+}
+
+// Allow reports whether the activity should be delivered. A nil filter allows everything.
+func (f *Filter) Allow(a Activity) bool {
+	if f == nil {
+		return true
+	}
+
+	return f.allow(f.Match(a))
+}
+
+// AllowEvent implements EventFilter. Unlike Allow it can match the program
+// diff of the event (see FilterRule.AssetKinds).
+func (f *Filter) AllowEvent(e ActivityEvent) bool {
+	if f == nil {
+		return true
+	}
+
+	return f.allow(f.MatchEvent(e))
+}
+
+func (f *Filter) allow(r *FilterRule) bool {
+	if r != nil {
+		return r.Action == FilterInclude
+	}
+
+	return f.Default != FilterExclude
+}
+
+// Match returns the first rule matching the activity or nil
+func (f *Filter) Match(a Activity) *FilterRule {
+	if f == nil {
+		return nil
+	}
+
+	for i := range f.Rules {
+		if f.Rules[i].Matches(a) {
+			return &f.Rules[i]
+		}
+	}
+
+	return nil
+}
+
+// MatchEvent returns the first rule matching the event or nil
+func (f *Filter) MatchEvent(e ActivityEvent) *FilterRule {
+	if f == nil {
+		return nil
+	}
+
+	for i := range f.Rules {
+		if f.Rules[i].MatchesEvent(e) {
+			return &f.Rules[i]
+		}
+	}
+
+	return nil
+}
+
+// MatchesEvent reports whether all conditions of the rule match the event
+func (r *FilterRule) MatchesEvent(e ActivityEvent) bool {
+	if len(r.AssetKinds) == 0 {
+		return r.Matches(e.Activity)
+	}
+
+	if !r.matchActivity(e.Activity) {
+		return false
+	}
+	for _, kind := range AssetKinds(e.Diff.NewAssets()) {
+		if containsFold(r.AssetKinds, kind) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matches reports whether all conditions of the rule match the activity.
+// Rules with AssetKinds never match as the activity carries no diff.
+func (r *FilterRule) Matches(a Activity) bool {
+	if len(r.AssetKinds) > 0 {
+		return false
+	}
+
+	return r.matchActivity(a)
+}
+
+func (r *FilterRule) matchActivity(a Activity) bool {
+	if len(r.Programs) > 0 && !containsFold(r.Programs, a.Programhandle) {
+		return false
+	}
+	if len(r.Companies) > 0 && !containsFold(r.Companies, a.Companyhandle) {
+		return false
+	}
+	if len(r.Discriminators) > 0 && !containsInt(r.Discriminators, a.Discriminator) {
+		return false
+	}
+
+	if len(r.Severities) > 0 {
+		if a.Discriminator != 3 || !containsFold(r.Severities, lookupName(severityIds, a.Newseverityid)) {
+			return false
+		}
+	}
+
+	if len(r.ClosedReasons) > 0 {
+		if a.Discriminator != 2 || a.Newstate.Status != 4 ||
+			!containsFold(r.ClosedReasons, lookupName(closedStates, a.Newstate.Closereason)) {
+			return false
+		}
+	}
+
+	if len(r.FromStatus) > 0 || len(r.ToStatus) > 0 {
+		t := a.Transition()
+		if t == nil {
+			return false
+		}
+		if len(r.FromStatus) > 0 && !matchStates(r.FromStatus, t.From, t.FromState) {
+			return false
+		}
+		if len(r.ToStatus) > 0 && !matchStates(r.ToStatus, t.To, t.ToState) {
+			return false
+		}
+	}
+
+	if len(r.Currencies) > 0 || r.MinPayout != nil || r.MaxPayout != nil {
+		if a.Discriminator != 5 {
+			return false
+		}
+		payout := a.NewPayoutAmount.Value
+		if len(r.Currencies) > 0 && !containsFold(r.Currencies, a.NewPayoutAmount.Currency) {
+			return false
+		}
+		if r.MinPayout != nil && payout < *r.MinPayout {
+			return false
+		}
+		if r.MaxPayout != nil && payout > *r.MaxPayout {
+			return false
+		}
+	}
+
+	if r.title != nil && !r.title.MatchString(a.Title) && !r.title.MatchString(a.Submissiontitle) {
+		return false
+	}
+	if r.description != nil && !r.description.MatchString(a.Description) {
+		return false
+	}
+
+	return true
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
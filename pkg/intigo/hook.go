@@ -0,0 +1,206 @@
+package intitools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHookTimeout     = 10 * time.Minute
+	defaultHookConcurrency = 1
+	hookOutputLength       = 500 // Logged output of failed hooks
+)
+
+// HookSink runs a command for every scope event (scope, out of scope and
+// domain updates). The command gets a JSON document (see HookEvent) on stdin.
+// Commands run in the background, at most Concurrency at a time; Deliver
+// blocks while all slots are taken. Exit status of every run is logged.
+// Running commands are not stopped when the pipeline stops, Close waits for
+// them (each at most Timeout).
+type HookSink struct {
+	Client      *Client
+	Command     []string      // Program and its arguments
+	Timeout     time.Duration // The command is killed after Timeout (default 10m)
+	Concurrency int           // Maximum number of running commands (default 1)
+
+	once    sync.Once
+	slots   chan struct{}
+	running sync.WaitGroup
+}
+
+// HookEvent is the document passed to hook commands
+type HookEvent struct {
+	Type          string         `json:"type"`
+	Discriminator int            `json:"discriminator"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	Program       HookProgram    `json:"program"`
+	Added         []ScopeAsset   `json:"added"`
+	Removed       []ScopeAsset   `json:"removed"`
+	Changed       []ScopeChange  `json:"changed"`
+	Diff          *ProgramDiff   `json:"diff"`
+	Text          string         `json:"text"`      // The diff as plain text in the display time zone
+	Conflicts     ScopeConflicts `json:"conflicts"` // Scope conflicts of the program (see ProgramScope.Lint)
+}
+
+type HookProgram struct {
+	Id            string `json:"id"`
+	Handle        string `json:"handle"`
+	Name          string `json:"name"`
+	CompanyHandle string `json:"companyHandle"`
+	URL           string `json:"url"`
+}
+
+// ScopeChange is an asset with changed details
+type ScopeChange struct {
+	Old ScopeAsset `json:"old"`
+	New ScopeAsset `json:"new"`
+}
+
+// NewHookSink returns sink running command (program and its arguments)
+func NewHookSink(c *Client, command []string, timeout time.Duration, concurrency int) *HookSink {
+	return &HookSink{Client: c, Command: command, Timeout: timeout, Concurrency: concurrency}
+}
+
+// IsScopeActivity reports whether the activity changes program scope
+func IsScopeActivity(discriminator int) bool {
+	return discriminator == 24 || discriminator == 25 || discriminator == 27
+}
+
+// NewHookEvent returns hook document of a scope event with its diff
+func NewHookEvent(e ActivityEvent, d *ProgramDiff) HookEvent {
+	a := e.Activity
+
+	h := HookEvent{
+		Type:          ActivityTypeName(a.Discriminator),
+		Discriminator: a.Discriminator,
+		CreatedAt:     a.Time().UTC(),
+		Program: HookProgram{
+			Id:            a.Programid,
+			Handle:        a.Programhandle,
+			Name:          a.Programname,
+			CompanyHandle: a.Companyhandle,
+			URL:           fmt.Sprintf("%s/researcher/programs/%s/%s/detail", AppURL, a.Companyhandle, a.Programhandle),
+		},
+		Added:   []ScopeAsset{},
+		Removed: []ScopeAsset{},
+		Changed: []ScopeChange{},
+		Diff:    d,
+	}
+
+	if d != nil {
+		for _, dom := range d.Added {
+			h.Added = append(h.Added, newScopeAsset(dom))
+		}
+		for _, dom := range d.Removed {
+			h.Removed = append(h.Removed, newScopeAsset(dom))
+		}
+		for _, chg := range d.Changed {
+			h.Changed = append(h.Changed, ScopeChange{Old: newScopeAsset(chg.Old), New: newScopeAsset(chg.New)})
+		}
+	}
+
+	return h
+}
+
+// Format returns the hook document of scope events with changes
+func (s *HookSink) Format(e ActivityEvent) (string, error) {
+	if !IsScopeActivity(e.Activity.Discriminator) {
+		return "", ErrEmptyMessage
+	}
+
+	d := s.Client.eventDiff(e)
+	if d.Empty() {
+		return "", ErrEmptyMessage
+	}
+
+	h := NewHookEvent(e, d)
+	h.Text = s.Client.DiffString(d)
+	h.Conflicts = s.Client.eventConflicts(e)
+	if h.Conflicts == nil {
+		h.Conflicts = ScopeConflicts{}
+	}
+
+	data, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// Deliver starts the command with the message on stdin
+func (s *HookSink) Deliver(ctx context.Context, message string) error {
+	if len(s.Command) == 0 {
+		return fmt.Errorf("hook command not defined")
+	}
+
+	s.once.Do(func() {
+		n := s.Concurrency
+		if n <= 0 {
+			n = defaultHookConcurrency
+		}
+		s.slots = make(chan struct{}, n)
+	})
+
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		defer func() { <-s.slots }()
+		s.run(message)
+	}()
+
+	return nil
+}
+
+// Close waits for running commands
+func (s *HookSink) Close() error {
+	s.running.Wait()
+	return nil
+}
+
+func (s *HookSink) run(message string) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+
+	// Not bound to the pipeline, a shutdown waits for the command (see Close)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.Command[0], s.Command[1:]...)
+	cmd.Stdin = strings.NewReader(message)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	name := strings.Join(s.Command, " ")
+	start := time.Now()
+	err := cmd.Run()
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		log.Printf("Hook %q killed after %s timeout\n", name, timeout)
+	case err != nil:
+		log.Printf("Hook %q failed after %s: %s\n", name, elapsed, err)
+		if out := strings.TrimSpace(output.String()); out != "" {
+			log.Printf("Hook %q output: %s\n", name, truncate(out, hookOutputLength))
+		}
+	default:
+		log.Printf("Hook %q finished after %s: exit status 0\n", name, elapsed)
+	}
+}
@@ -21,6 +21,9 @@ const (
 	ApiURL   = "https://app.intigriti.com/api"
 	AppURL   = "https://app.intigriti.com"
 	LoginURL = "https://login.intigriti.com"
+
+	DefaultTimeFormat = "2006-01-02 15:04 MST"
+	DefaultProgramTTL = time.Minute
 )
 
 type Client struct {
@@ -34,6 +37,14 @@ type Client struct {
 	secret        string
 	LastViewed    int64
 	WebhookURL    string
+	UnknownLog    string         // Path of unrecognized activities log (empty disables logging)
+	AttachUnknown bool           // Attach raw payload of unrecognized activities to notifications
+	Location      *time.Location // Display time zone (default local)
+	Snapshots     *SnapshotStore // Store for snapshots of fetched programs (optional)
+	TimeFormat    string         // Display time format (default DefaultTimeFormat)
+	ProgramTTL    time.Duration  // How long fetched programs are reused (default DefaultProgramTTL, negative disables)
+	DiffMode      string         // Diff mode of program text (default DefaultDiffMode, see DiffModes)
+	programs      *programCache
 	Ratelimiter   *rate.Limiter
 	HTTPClient    *http.Client
 	HttpCtx       context.Context
@@ -46,7 +57,7 @@ type ResponseState struct {
 }
 
 type ResponsePayout struct {
-	Value    float32 `json:"value"`
+	Value    float64 `json:"value"`
 	Currency string  `json:"currency"`
 }
 
@@ -58,6 +69,15 @@ type ResponseUser struct {
 	Username string `json:"userName"`
 }
 
+// StatusError is returned for API responses with unexpected status code
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unknown error, status code: %d", e.StatusCode)
+}
+
 func NewClient(username string, password string, secret string, rl *rate.Limiter) *Client {
 
 	jar, err := cookiejar.New(nil)
@@ -85,6 +105,7 @@ func NewClient(username string, password string, secret string, rl *rate.Limiter
 		},
 		Authenticated: false,
 		Ratelimiter:   rl,
+		programs:      newProgramCache(),
 	}
 }
 
@@ -356,28 +377,48 @@ func (c *Client) Authenticate() error {
 	return nil
 }
 
+// sendRequest sends request and decodes JSON response into v
 func (c *Client) sendRequest(req *http.Request, v interface{}) error {
+	_, _, err := c.sendConditionalRequest(req, "", v)
+	return err
+}
+
+// sendConditionalRequest sends request with If-None-Match set to etag (if
+// not empty). It returns ETag of the response and whether the resource was
+// not modified, in which case v is left untouched.
+func (c *Client) sendConditionalRequest(req *http.Request, etag string, v interface{}) (string, bool, error) {
 
 	if !c.Authenticated {
 		c.Authenticate()
 	}
 	req.Header.Set("Accept", "application/json; charset=utf-8")
 	//req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
 
 	res, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return err
+		return "", false, err
 	}
 
 	defer res.Body.Close()
 
+	if res.StatusCode == http.StatusNotModified {
+		// Unexpected without If-None-Match, v would be left empty
+		if etag == "" {
+			return "", false, &StatusError{StatusCode: res.StatusCode}
+		}
+		return etag, true, nil
+	}
+
 	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
-		return fmt.Errorf("unknown error, status code: %d", res.StatusCode)
+		return "", false, &StatusError{StatusCode: res.StatusCode}
 	}
 
 	if err = json.NewDecoder(res.Body).Decode(&v); err != nil {
-		return err
+		return "", false, err
 	}
 
-	return nil
+	return res.Header.Get("ETag"), false, nil
 }
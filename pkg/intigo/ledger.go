@@ -0,0 +1,272 @@
+package intitools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ledger is a local record of payouts kept as JSON lines in a single file
+type Ledger struct {
+	path string
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+type LedgerEntry struct {
+	Key             string    `json:"key"`
+	PaidAt          time.Time `json:"paidAt"`
+	SubmissionCode  string    `json:"submissionCode"`
+	SubmissionTitle string    `json:"submissionTitle"`
+	ProgramId       string    `json:"programId"`
+	ProgramHandle   string    `json:"programHandle"`
+	ProgramName     string    `json:"programName"`
+	CompanyHandle   string    `json:"companyHandle"`
+	Currency        string    `json:"currency"`
+	Cents           int64     `json:"cents"` // Amount in cents to keep it exact
+	PayoutType      int       `json:"payoutType"`
+}
+
+// LedgerTotal is a sum of payouts in a single currency
+type LedgerTotal struct {
+	Group    string // Month, program or currency depending on grouping
+	Currency string
+	Cents    int64
+	Count    int
+}
+
+// Rates are conversion rates to a single currency (1 unit of currency = rate units)
+type Rates map[string]float64
+
+// OpenLedger opens (and creates if needed) the ledger file
+func OpenLedger(path string) (*Ledger, error) {
+	l := &Ledger{path: path, keys: make(map[string]bool)}
+
+	entries, err := l.Entries()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		l.keys[e.Key] = true
+	}
+
+	return l, nil
+}
+
+// NewLedgerEntry returns ledger entry of a payout activity
+func NewLedgerEntry(a Activity) (LedgerEntry, error) {
+	if a.Discriminator != 5 {
+		return LedgerEntry{}, fmt.Errorf("activity %d is not a payout", a.Discriminator)
+	}
+
+	return LedgerEntry{
+		Key:             activityKey(a),
+		PaidAt:          time.Unix(0, a.CreatedAt*int64(time.Millisecond)).UTC(),
+		SubmissionCode:  a.Submissioncode,
+		SubmissionTitle: a.Submissiontitle,
+		ProgramId:       a.Programid,
+		ProgramHandle:   a.Programhandle,
+		ProgramName:     a.Programname,
+		CompanyHandle:   a.Companyhandle,
+		Currency:        strings.ToUpper(a.NewPayoutAmount.Currency),
+		Cents:           int64(math.Round(a.NewPayoutAmount.Value * 100)),
+		PayoutType:      a.NewPayoutType,
+	}, nil
+}
+
+// Amount returns the amount as a decimal string, e.g. "120.50"
+func (e LedgerEntry) Amount() string {
+	return FormatCents(e.Cents)
+}
+
+// Add stores the entry unless it is already in the ledger
+func (l *Ledger) Add(e LedgerEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.keys[e.Key] {
+		return nil
+	}
+
+	if err := appendJSONLine(l.path, e); err != nil {
+		return err
+	}
+	l.keys[e.Key] = true
+
+	return nil
+}
+
+// Entries returns all ledger entries ordered by payout time
+func (l *Ledger) Entries() ([]LedgerEntry, error) {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []LedgerEntry
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var e LedgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", l.path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].PaidAt.Before(entries[j].PaidAt)
+	})
+
+	return entries, nil
+}
+
+// LedgerTotals sums entries by "month", "program" or "currency". Totals are
+// kept per currency unless rates are given, in which case all amounts are
+// converted to currency (and grouping by "currency" gives a single total).
+func LedgerTotals(entries []LedgerEntry, by string, loc *time.Location, rates Rates, currency string) ([]LedgerTotal, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+
+	totals := make(map[[2]string]*LedgerTotal)
+	var order [][2]string
+
+	for _, e := range entries {
+		cur, cents := e.Currency, e.Cents
+		if rates != nil {
+			var err error
+			if cents, err = rates.Convert(e.Currency, e.Cents, currency); err != nil {
+				return nil, err
+			}
+			cur = strings.ToUpper(currency)
+		}
+
+		var group string
+		switch by {
+		case "month":
+			group = e.PaidAt.In(loc).Format("2006-01")
+		case "program":
+			group = e.ProgramHandle
+		case "currency":
+			group = cur
+		default:
+			return nil, fmt.Errorf("unknown grouping %q", by)
+		}
+
+		key := [2]string{group, cur}
+		t, ok := totals[key]
+		if !ok {
+			t = &LedgerTotal{Group: group, Currency: cur}
+			totals[key] = t
+			order = append(order, key)
+		}
+		t.Cents += cents
+		t.Count++
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i][0] != order[j][0] {
+			return order[i][0] < order[j][0]
+		}
+		return order[i][1] < order[j][1]
+	})
+
+	list := make([]LedgerTotal, 0, len(order))
+	for _, key := range order {
+		list = append(list, *totals[key])
+	}
+
+	return list, nil
+}
+
+// LoadRates reads conversion rates from a file with "CURRENCY RATE" lines,
+// e.g. "USD 0.92" for converting USD to EUR. Empty lines and lines starting
+// with # are ignored.
+func LoadRates(path string) (Rates, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rates := make(Rates)
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected CURRENCY RATE", path, line)
+		}
+		rate, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("%s:%d: invalid rate %q", path, line, fields[1])
+		}
+		rates[strings.ToUpper(fields[0])] = rate
+	}
+
+	return rates, scanner.Err()
+}
+
+// Convert converts amount in cents from currency from to currency to
+func (r Rates) Convert(from string, cents int64, to string) (int64, error) {
+	if strings.EqualFold(from, to) {
+		return cents, nil
+	}
+
+	rate, ok := r[strings.ToUpper(from)]
+	if !ok {
+		return 0, fmt.Errorf("no conversion rate for %s", from)
+	}
+
+	return int64(math.Round(float64(cents) * rate)), nil
+}
+
+// FormatCents formats amount in cents as a decimal string, e.g. "120.50"
+func FormatCents(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// LedgerMiddleware records every payout passing through the pipeline
+func LedgerMiddleware(l *Ledger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, e *ActivityEvent) error {
+			if e.Activity.Discriminator == 5 {
+				entry, err := NewLedgerEntry(e.Activity)
+				if err == nil {
+					err = l.Add(entry)
+				}
+				if err != nil {
+					log.Printf("Ledger error: %s\n", err)
+				}
+			}
+			return next(ctx, e)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package intitools
+
+import (
+	"testing"
+	"time"
+)
+
+var ledgerEntries = []LedgerEntry{
+	{PaidAt: time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC), ProgramHandle: "acme", Currency: "EUR", Cents: 10000},
+	{PaidAt: time.Date(2024, 1, 20, 12, 0, 0, 0, time.UTC), ProgramHandle: "acme", Currency: "USD", Cents: 5000},
+	{PaidAt: time.Date(2024, 2, 5, 12, 0, 0, 0, time.UTC), ProgramHandle: "globex", Currency: "EUR", Cents: 2550},
+}
+
+func TestLedgerTotals(t *testing.T) {
+	rates := Rates{"USD": 0.9}
+
+	tests := []struct {
+		name  string
+		by    string
+		rates Rates
+		want  []LedgerTotal
+	}{
+		{"month per currency", "month", nil, []LedgerTotal{
+			{"2024-01", "EUR", 10000, 1},
+			{"2024-01", "USD", 5000, 1},
+			{"2024-02", "EUR", 2550, 1},
+		}},
+		{"program per currency", "program", nil, []LedgerTotal{
+			{"acme", "EUR", 10000, 1},
+			{"acme", "USD", 5000, 1},
+			{"globex", "EUR", 2550, 1},
+		}},
+		{"currency", "currency", nil, []LedgerTotal{
+			{"EUR", "EUR", 12550, 2},
+			{"USD", "USD", 5000, 1},
+		}},
+		{"month converted", "month", rates, []LedgerTotal{
+			{"2024-01", "EUR", 14500, 2},
+			{"2024-02", "EUR", 2550, 1},
+		}},
+		{"currency converted", "currency", rates, []LedgerTotal{
+			{"EUR", "EUR", 17050, 3},
+		}},
+	}
+
+	for _, tt := range tests {
+		got, err := LedgerTotals(ledgerEntries, tt.by, time.UTC, tt.rates, "eur")
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: total %d = %v, want %v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestLedgerTotalsErrors(t *testing.T) {
+	if _, err := LedgerTotals(ledgerEntries, "month", time.UTC, Rates{"GBP": 1.15}, "EUR"); err == nil {
+		t.Error("missing rate: expected error")
+	}
+	if _, err := LedgerTotals(ledgerEntries, "year", time.UTC, nil, ""); err == nil {
+		t.Error("unknown grouping: expected error")
+	}
+}
+
+func TestRatesConvert(t *testing.T) {
+	rates := Rates{"USD": 0.92}
+
+	tests := []struct {
+		from  string
+		cents int64
+		to    string
+		want  int64
+		err   bool
+	}{
+		{"EUR", 1000, "EUR", 1000, false},
+		{"eur", 1000, "EUR", 1000, false},
+		{"USD", 1000, "EUR", 920, false},
+		{"usd", 1, "EUR", 1, false},
+		{"GBP", 1000, "EUR", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := rates.Convert(tt.from, tt.cents, tt.to)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("Convert(%s, %d, %s) = %d, %v, want %d (error %t)", tt.from, tt.cents, tt.to, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestFormatCents(t *testing.T) {
+	tests := map[int64]string{
+		0:      "0.00",
+		5:      "0.05",
+		12050:  "120.50",
+		-12050: "-120.50",
+	}
+
+	for cents, want := range tests {
+		if got := FormatCents(cents); got != want {
+			t.Errorf("FormatCents(%d) = %q, want %q", cents, got, want)
+		}
+	}
+}
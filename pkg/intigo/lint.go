@@ -0,0 +1,266 @@
+package intitools
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+const (
+	conflictContextLength  = 160 // Quoted text of a conflict
+	conflictEndpointLength = 100 // Endpoint and mention shown in a conflict
+	maxConflictsShown      = 5   // Conflicts listed in notifications
+)
+
+var (
+	// Host names, wildcards and URLs mentioned in program texts
+	mentionPattern = regexp.MustCompile("(?i)(?:https?://)?(?:\\*\\.)?(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\\.)+[a-z]{2,63}(?::\\d+)?(?:/[^\\s)\\]\"'<>`]*)?")
+	sentenceEnd    = regexp.MustCompile(`[.!?]+(\s+|$)|\n+`)
+	exclusionWords = regexp.MustCompile(`(?i)\b(out[- ]of[- ]scope|not in[- ]scope|excluded|exclude|excluding|except|not eligible|not covered|no bount)`)
+
+	// File extensions looking like top level domains
+	fileExtensions = []string{"js", "json", "php", "html", "htm", "txt", "xml", "png", "jpg", "jpeg", "gif", "svg", "pdf", "zip", "md", "exe", "yml", "yaml", "py", "go", "sh", "css", "csv", "apk", "ipa"}
+)
+
+// ScopeConflict is a domain contradicted by program texts or other domains,
+// e.g. an in-scope domain which the out of scope text still excludes
+type ScopeConflict struct {
+	Endpoint string `json:"endpoint"`
+	Tier     string `json:"tier"`
+	InScope  bool   `json:"inScope"`
+	Mention  string `json:"mention"` // Conflicting host, wildcard or URL
+	Source   string `json:"source"`  // Where the mention was found, e.g. "out of scope text"
+	Context  string `json:"context"` // Sentence with the mention
+}
+
+// ScopeConflicts is the result of Lint
+type ScopeConflicts []ScopeConflict
+
+// mention is a host, wildcard or URL found in a sentence
+type mention struct {
+	asset    Asset
+	sentence string
+	excludes bool // The sentence excludes something
+}
+
+// findMentions returns network assets mentioned in text
+func findMentions(text string) []mention {
+	var mentions []mention
+	for _, sentence := range sentenceEnd.Split(stripMarkdown(text), -1) {
+		sentence = strings.Join(strings.Fields(sentence), " ")
+		excludes := exclusionWords.MatchString(sentence)
+		for _, m := range mentionPattern.FindAllString(sentence, -1) {
+			a := parseNetworkAsset(strings.TrimRight(m, ".,;:"))
+			if a.Kind != AssetWildcard && a.Kind != AssetHost && a.Kind != AssetURL {
+				continue
+			}
+			if containsFold(fileExtensions, a.Host[strings.LastIndex(a.Host, ".")+1:]) {
+				continue
+			}
+			a.Raw = m
+			mentions = append(mentions, mention{asset: a, sentence: sentence, excludes: excludes})
+		}
+	}
+	return mentions
+}
+
+// coversAsset reports whether the mentioned asset ex refers to a (or to a
+// wider part of the scope containing a)
+func coversAsset(ex Asset, a Asset) bool {
+	switch ex.Kind {
+	case AssetWildcard:
+		return a.Host != "" && (a.Host == ex.Host || strings.HasSuffix(a.Host, "."+ex.Host))
+	case AssetHost:
+		return (a.Kind == AssetHost || a.Kind == AssetURL) && a.Host == ex.Host && !strings.Contains(a.Value, "*")
+	case AssetURL:
+		return a.Kind == AssetURL && (a.Value == ex.Value || strings.HasPrefix(a.Value, ex.Value+"/"))
+	}
+	return false
+}
+
+// Lint cross-checks domains against the host names and wildcards mentioned
+// in the out of scope text, in the in scope text and in domain descriptions.
+// It reports in-scope domains excluded by the out of scope text or by an
+// exclusion in a description, domains listed both in and out of scope and
+// out-of-scope domains listed as in scope by the in scope text.
+func (s *ProgramScope) Lint() ScopeConflicts {
+	conflicts := ScopeConflicts{}
+	seen := make(map[string]bool)
+
+	add := func(sa ScopeAsset, m mention, source string) {
+		key := sa.Endpoint + "\n" + m.asset.Value + "\n" + source
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		conflicts = append(conflicts, ScopeConflict{
+			Endpoint: sa.Endpoint,
+			Tier:     sa.Tier,
+			InScope:  sa.InScope,
+			Mention:  m.asset.Value,
+			Source:   source,
+			Context:  truncate(m.sentence, conflictContextLength),
+		})
+	}
+
+	// check reports domains (in or out of scope) covered by the mentions
+	check := func(mentions []mention, inScope bool, source string) {
+		for _, m := range mentions {
+			for _, sa := range s.Assets {
+				if sa.InScope != inScope {
+					continue
+				}
+				for _, a := range sa.Assets {
+					if coversAsset(m.asset, a) {
+						add(sa, m, source)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	check(findMentions(s.OutOfScope), true, "out of scope text")
+
+	var listed []mention
+	for _, m := range findMentions(s.InScope) {
+		if !m.excludes {
+			listed = append(listed, m)
+		}
+	}
+	check(listed, false, "in scope text")
+
+	for _, sa := range s.Assets {
+		var excluded []mention
+		for _, m := range findMentions(sa.Description) {
+			if m.excludes {
+				excluded = append(excluded, m)
+			}
+		}
+		check(excluded, true, fmt.Sprintf("description of %s", sa.Endpoint))
+	}
+
+	// The same asset listed with an in-scope tier and as out of scope
+	for _, out := range s.Assets {
+		if out.InScope {
+			continue
+		}
+		for _, in := range s.Assets {
+			if !in.InScope {
+				continue
+			}
+			for _, a := range out.Assets {
+				if a.Kind != AssetText && containsAsset(in.Assets, a) {
+					add(in, mention{asset: a, sentence: out.Endpoint}, "out of scope domains")
+				}
+			}
+		}
+	}
+
+	return conflicts
+}
+
+func containsAsset(assets []Asset, a Asset) bool {
+	for _, v := range assets {
+		if v.Key() == a.Key() {
+			return true
+		}
+	}
+	return false
+}
+
+// LintProgram checks the latest version of the program for scope conflicts
+func LintProgram(p *Program) ScopeConflicts {
+	return NewProgramScope(p).Lint()
+}
+
+// ScopeConflicts checks the program changed by the activity for scope
+// conflicts
+func (c *Client) ScopeConflicts(a Activity) (ScopeConflicts, error) {
+	p, err := c.activityProgram(a)
+	if err != nil {
+		return nil, err
+	}
+	return LintProgram(p), nil
+}
+
+// eventConflicts returns scope conflicts of scope events (nil for other events)
+func (c *Client) eventConflicts(e ActivityEvent) ScopeConflicts {
+	if !IsScopeActivity(e.Activity.Discriminator) {
+		return nil
+	}
+	if e.Conflicts != nil {
+		return e.Conflicts
+	}
+	conflicts, err := c.ScopeConflicts(e.Activity)
+	if err != nil {
+		log.Printf("Cannot check scope conflicts: %s\n", err)
+	}
+	return conflicts
+}
+
+// String describes the conflict in plain text
+func (sc ScopeConflict) String() string {
+	return sc.render(plainDiffStyle)
+}
+
+func (sc ScopeConflict) render(st diffStyle) string {
+	endpoint := st.code(st.escape(truncate(sc.Endpoint, conflictEndpointLength)))
+	if sc.InScope {
+		endpoint += " (" + st.escape(sc.Tier) + ")"
+	}
+	if sc.Source == "out of scope domains" {
+		return fmt.Sprintf("%s is listed both in scope and out of scope", endpoint)
+	}
+
+	what := "is in scope but excluded by"
+	if !sc.InScope {
+		what = "is out of scope but listed by"
+	}
+
+	text := fmt.Sprintf("%s %s %s", endpoint, what, st.escape(sc.Source))
+	if sc.Mention != "" && !strings.EqualFold(sc.Mention, sc.Endpoint) {
+		text += " (" + st.code(st.escape(truncate(sc.Mention, conflictEndpointLength))) + ")"
+	}
+	return fmt.Sprintf("%s: \"%s\"", text, st.escape(truncate(sc.Context, conflictContextLength)))
+}
+
+// String renders the conflicts as plain text (empty without conflicts)
+func (cs ScopeConflicts) String() string {
+	return cs.render(plainDiffStyle, 0)
+}
+
+// Slack renders the conflicts as Slack mrkdwn (empty without conflicts)
+func (cs ScopeConflicts) Slack() string {
+	return cs.render(slackDiffStyle, 0)
+}
+
+// Markdown renders the conflicts as Discord flavoured markdown (empty without
+// conflicts)
+func (cs ScopeConflicts) Markdown() string {
+	return cs.render(markdownDiffStyle, 0)
+}
+
+// render lists at most maxConflictsShown conflicts in at most max bytes (0
+// for no limit)
+func (cs ScopeConflicts) render(st diffStyle, max int) string {
+	if len(cs) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\n")
+	b.WriteString(st.bold(st.escape(fmt.Sprintf("⚠ Scope conflicts (%d):", len(cs)))))
+	b.WriteString("\n")
+	for i, sc := range cs {
+		line := " - " + sc.render(st) + "\n"
+		if i == maxConflictsShown || (max > 0 && b.Len()+len(line)+diffReserve > max) {
+			b.WriteString(st.escape(fmt.Sprintf("[...] %d more", len(cs)-i)))
+			b.WriteString("\n")
+			break
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
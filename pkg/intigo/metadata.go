@@ -0,0 +1,261 @@
+package intitools
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ActivityProgramMetadata is the synthetic activity type emitted by
+// WatchMetadata for a program field changed without any activity
+const ActivityProgramMetadata = 1003
+
+const defaultMetadataInterval = 6 * time.Hour
+
+// MetadataFields lists program fields compared by WatchMetadata (see DiffPrograms)
+var MetadataFields = []string{"Name", "ConfidentialityLevel", "MinBounty", "MaxBounty", "IdentityCheckedRequired", "AwardRep", "SkipTriage"}
+
+type MetadataOptions struct {
+	Interval time.Duration  // Polling interval (default 6h)
+	Programs []string       // Followed programs as "company/handle" (default all joined programs)
+	Store    *SnapshotStore // Previous versions (optional). Without it the first poll of a program only records it.
+	Buffer   int            // Size of the event channel buffer (default 16)
+}
+
+// MetadataChanges returns changes of the fields in MetadataFields. Bounties
+// are formatted in the currency of the bounty table, e.g. "€20,000".
+func MetadataChanges(old *Program, new *Program) []FieldChange {
+	var changes []FieldChange
+	for _, fc := range DiffPrograms(old, new) {
+		if fc.Field == "MinBounty" || fc.Field == "MaxBounty" {
+			fc.Old, fc.New = programBounty(old, fc.Old), programBounty(new, fc.New)
+			if fc.Old == fc.New {
+				continue
+			}
+		}
+		for _, f := range MetadataFields {
+			if fc.Field == f {
+				changes = append(changes, fc)
+				break
+			}
+		}
+	}
+	return changes
+}
+
+// programBounty formats the min or max bounty of the program with the
+// currency of its bounty table
+func programBounty(p *Program, amount string) string {
+	value, err := parseAmount(amount)
+	if err != nil {
+		return amount
+	}
+	min, max := latestBounties(p.BountyTables).Range()
+	currency := max.Currency
+	if currency == "" {
+		currency = min.Currency
+	}
+	return FormatMoney(currency, value)
+}
+
+// MetadataSentence describes the change as a predicate of the program, e.g.
+// "now requires identity check" or "raised max bounty to €20,000"
+func MetadataSentence(fc FieldChange) string {
+	flag := func(on string, off string) string {
+		if fc.New == "true" {
+			return on
+		}
+		return off
+	}
+
+	switch fc.Field {
+	case "Name":
+		return fmt.Sprintf("was renamed from %q to %q", fc.Old, fc.New)
+	case "ConfidentialityLevel":
+		return fmt.Sprintf("changed confidentiality level from %s to %s", fc.Old, fc.New)
+	case "MinBounty":
+		return bountySentence("min", fc)
+	case "MaxBounty":
+		return bountySentence("max", fc)
+	case "IdentityCheckedRequired":
+		return flag("now requires identity check", "no longer requires identity check")
+	case "AwardRep":
+		return flag("now awards reputation", "no longer awards reputation")
+	case "SkipTriage":
+		return flag("now skips triage", "no longer skips triage")
+	}
+	return fmt.Sprintf("changed %s from %q to %q", fc.Field, fc.Old, fc.New)
+}
+
+func bountySentence(which string, fc FieldChange) string {
+	switch {
+	case fc.Old == "":
+		return fmt.Sprintf("set %s bounty to %s", which, fc.New)
+	case fc.New == "":
+		return fmt.Sprintf("removed %s bounty (was %s)", which, fc.Old)
+	}
+
+	o, errOld := parseAmount(fc.Old)
+	n, errNew := parseAmount(fc.New)
+	switch {
+	case errOld != nil || errNew != nil:
+		return fmt.Sprintf("changed %s bounty from %s to %s", which, fc.Old, fc.New)
+	case n > o:
+		return fmt.Sprintf("raised %s bounty to %s (was %s)", which, fc.New, fc.Old)
+	default:
+		return fmt.Sprintf("lowered %s bounty to %s (was %s)", which, fc.New, fc.Old)
+	}
+}
+
+// parseAmount returns the number of an amount like "€20,000"
+func parseAmount(s string) (float64, error) {
+	number := strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '.' {
+			return r
+		}
+		return -1
+	}, s)
+	return strconv.ParseFloat(number, 64)
+}
+
+// metadataEvent returns the synthetic event of a single field change
+func metadataEvent(p *Program, fc FieldChange, from time.Time, now time.Time) ActivityEvent {
+	return ActivityEvent{
+		Activity: Activity{
+			Discriminator: ActivityProgramMetadata,
+			Title:         MetadataSentence(fc),
+			CreatedAt:     now.UnixNano() / int64(time.Millisecond),
+			Programid:     p.ProgramId,
+			Programlogoid: p.LogoId,
+			Programname:   p.Name,
+			Programhandle: p.Handle,
+			Companyhandle: p.CompanyHandle,
+		},
+		Known:      true,
+		ReceivedAt: now,
+		Diff: &ProgramDiff{
+			Section: "Metadata",
+			From:    from,
+			To:      now,
+			Fields:  []FieldChange{fc},
+		},
+	}
+}
+
+// metadataState is the last seen version of a followed program
+type metadataState struct {
+	program *Program
+	seen    time.Time
+}
+
+// WatchMetadata periodically fetches followed programs and compares them with
+// the previous version. Every changed field in MetadataFields is emitted as a
+// synthetic ActivityProgramMetadata event with the change in Diff.Fields.
+// Errors are reported as events with Err set. The channel is closed when ctx
+// is cancelled.
+func (c *Client) WatchMetadata(ctx context.Context, opts MetadataOptions) (<-chan ActivityEvent, error) {
+	if opts.Interval < 0 || opts.Buffer < 0 {
+		return nil, fmt.Errorf("invalid metadata options")
+	}
+	if opts.Interval == 0 {
+		opts.Interval = defaultMetadataInterval
+	}
+	if opts.Buffer == 0 {
+		opts.Buffer = defaultWatchBuffer
+	}
+
+	out := make(chan ActivityEvent, opts.Buffer)
+
+	go func() {
+		defer close(out)
+
+		state := make(map[string]*metadataState)
+
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+
+			events, err := c.pollMetadata(ctx, opts, state)
+			if err != nil {
+				events = append(events, ActivityEvent{Err: fmt.Errorf("Metadata error: %w", err)})
+			}
+
+			for _, e := range events {
+				select {
+				case out <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			timer.Reset(opts.Interval)
+		}
+	}()
+
+	return out, nil
+}
+
+func (c *Client) pollMetadata(ctx context.Context, opts MetadataOptions, state map[string]*metadataState) ([]ActivityEvent, error) {
+	handles := opts.Programs
+	if len(handles) == 0 {
+		joined, err := c.GetJoinedPrograms(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range joined {
+			handles = append(handles, p.CompanyHandle+"/"+p.Handle)
+		}
+	}
+
+	var events []ActivityEvent
+	for _, h := range handles {
+		i := strings.Index(h, "/")
+		if i <= 0 || i == len(h)-1 {
+			log.Printf("Invalid program %q (expected COMPANY/HANDLE)\n", h)
+			continue
+		}
+		company, handle := h[:i], h[i+1:]
+
+		// The stored version must be read before the fetch saves a new one
+		prev := state[h]
+		if prev == nil && opts.Store != nil {
+			snap, err := opts.Store.Latest(company, handle)
+			if err != nil {
+				log.Printf("Cannot read snapshot of %s: %s\n", h, err)
+			} else if snap != nil {
+				prev = &metadataState{program: &snap.Program, seen: snap.FetchedAt}
+			}
+		}
+
+		p, err := c.GetProgram(ctx, company, handle)
+		if err != nil {
+			log.Printf("Cannot fetch program %s: %s\n", h, err)
+			continue
+		}
+		// GetProgram already saves to the client's own store
+		if opts.Store != nil && opts.Store != c.Snapshots {
+			if _, err := opts.Store.Save(p); err != nil {
+				log.Printf("Cannot save snapshot of %s: %s\n", h, err)
+			}
+		}
+
+		now := time.Now()
+		if prev != nil {
+			for _, fc := range MetadataChanges(prev.program, p) {
+				events = append(events, metadataEvent(p, fc, prev.seen, now))
+			}
+		}
+		state[h] = &metadataState{program: p, seen: now}
+	}
+
+	return events, nil
+}
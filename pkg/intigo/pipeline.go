@@ -0,0 +1,253 @@
+package intitools
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+)
+
+const defaultRouteQueue = 64
+
+/*	Event pipeline
+
+source (Watch) → filter → enrich → [middleware] → route → format → deliver
+
+Every route has its own bounded queue and goroutine so a slow webhook does
+not block polling or other routes until its queue is full.
+*/
+
+// EventFilter decides whether an event continues through the pipeline
+type EventFilter interface {
+	AllowEvent(e ActivityEvent) bool
+}
+
+// Enricher adds details to an event
+type Enricher interface {
+	Enrich(ctx context.Context, e *ActivityEvent) error
+}
+
+// Sink formats events and delivers the formatted messages. The message is
+// passed from Format to Deliver unchanged and its encoding is up to the sink.
+type Sink interface {
+	Format(e ActivityEvent) (string, error)
+	Deliver(ctx context.Context, message string) error
+}
+
+// Handler processes a single event
+type Handler func(ctx context.Context, e *ActivityEvent) error
+
+// Middleware wraps event handling between enrichment and routing. It may
+// inspect or modify the event, or drop it by not calling next.
+type Middleware func(next Handler) Handler
+
+// Route delivers events accepted by Filter to Sink
+type Route struct {
+	Name   string
+	Filter EventFilter // nil accepts everything
+	Sink   Sink
+	Queue  int // Size of the route queue (default 64)
+
+	queue chan ActivityEvent
+}
+
+type Pipeline struct {
+	Source     <-chan ActivityEvent
+	Filters    []EventFilter
+	Enrichers  []Enricher
+	Middleware []Middleware
+	Routes     []*Route
+
+	// OnError is called for polling, enrichment, formatting and delivery
+	// errors. Errors are logged if not set.
+	OnError func(stage string, e ActivityEvent, err error)
+}
+
+// NewPipeline returns a pipeline reading events from source
+func NewPipeline(source <-chan ActivityEvent) *Pipeline {
+	return &Pipeline{Source: source}
+}
+
+func (p *Pipeline) AddFilter(f EventFilter) {
+	p.Filters = append(p.Filters, f)
+}
+
+func (p *Pipeline) AddEnricher(e Enricher) {
+	p.Enrichers = append(p.Enrichers, e)
+}
+
+func (p *Pipeline) AddRoute(r *Route) {
+	p.Routes = append(p.Routes, r)
+}
+
+// Use adds middleware. Middleware added first is the outermost one.
+func (p *Pipeline) Use(mw ...Middleware) {
+	p.Middleware = append(p.Middleware, mw...)
+}
+
+// MergeEvents returns a channel with events of all sources. It is closed
+// when all sources are closed or ctx is cancelled.
+func MergeEvents(ctx context.Context, sources ...<-chan ActivityEvent) <-chan ActivityEvent {
+	out := make(chan ActivityEvent)
+
+	var wg sync.WaitGroup
+	for _, src := range sources {
+		wg.Add(1)
+		go func(src <-chan ActivityEvent) {
+			defer wg.Done()
+			for e := range src {
+				select {
+				case out <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(src)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// Run processes events until the source is closed or ctx is cancelled. It
+// returns when routes are drained and their sinks closed.
+func (p *Pipeline) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+
+	for _, r := range p.Routes {
+		size := r.Queue
+		if size <= 0 {
+			size = defaultRouteQueue
+		}
+		r.queue = make(chan ActivityEvent, size)
+
+		wg.Add(1)
+		go func(r *Route) {
+			defer wg.Done()
+			p.runRoute(ctx, r)
+		}(r)
+	}
+
+	handler := p.dispatch
+	for i := len(p.Middleware) - 1; i >= 0; i-- {
+		handler = p.Middleware[i](handler)
+	}
+
+	defer func() {
+		for _, r := range p.Routes {
+			close(r.queue)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case e, ok := <-p.Source:
+			if !ok {
+				return nil
+			}
+
+			if e.Err != nil {
+				p.error("source", e, e.Err)
+				continue
+			}
+
+			if !p.allow(e) {
+				continue
+			}
+
+			for _, enricher := range p.Enrichers {
+				if err := enricher.Enrich(ctx, &e); err != nil {
+					p.error("enrich", e, err)
+				}
+			}
+
+			if err := handler(ctx, &e); err != nil {
+				p.error("handle", e, err)
+			}
+		}
+	}
+}
+
+func (p *Pipeline) allow(e ActivityEvent) bool {
+	for _, f := range p.Filters {
+		if !f.AllowEvent(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// dispatch queues the event on all routes accepting it
+func (p *Pipeline) dispatch(ctx context.Context, e *ActivityEvent) error {
+	for _, r := range p.Routes {
+		if r.Filter != nil && !r.Filter.AllowEvent(*e) {
+			continue
+		}
+
+		select {
+		case r.queue <- *e:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// runRoute delivers queued events until the queue is closed. Sinks with
+// background work (io.Closer) are closed afterwards.
+func (p *Pipeline) runRoute(ctx context.Context, r *Route) {
+	if c, ok := r.Sink.(io.Closer); ok {
+		defer func() {
+			if err := c.Close(); err != nil {
+				p.error("close "+r.Name, ActivityEvent{}, err)
+			}
+		}()
+	}
+
+	for e := range r.queue {
+		if ctx.Err() != nil {
+			continue
+		}
+
+		message, err := r.Sink.Format(e)
+		if err != nil {
+			if err != ErrEmptyMessage {
+				p.error("format "+r.Name, e, err)
+			}
+			continue
+		}
+
+		if err := r.Sink.Deliver(ctx, message); err != nil {
+			p.error("deliver "+r.Name, e, err)
+		}
+	}
+}
+
+func (p *Pipeline) error(stage string, e ActivityEvent, err error) {
+	if p.OnError != nil {
+		p.OnError(stage, e, err)
+		return
+	}
+	log.Printf("Pipeline %s error: %s\n", stage, err)
+}
+
+// RecordMiddleware appends every event passing through the pipeline to the
+// activity log at path (see RecordActivity)
+func RecordMiddleware(path string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, e *ActivityEvent) error {
+			if err := RecordActivity(path, e.Activity); err != nil {
+				log.Printf("Record error: %s\n", err)
+			}
+			return next(ctx, e)
+		}
+	}
+}
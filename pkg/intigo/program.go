@@ -1,14 +1,12 @@
 package intitools
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
 	"net/url"
 	"time"
-
-	"github.com/hexops/gotextdiff"
-	"github.com/hexops/gotextdiff/myers"
-	"github.com/hexops/gotextdiff/span"
 )
 
 type Program struct {
@@ -34,6 +32,7 @@ type Program struct {
 	Faqs                    []ProgramChanges      `json:"faqs"`
 	SeverityAssessments     []ProgramChanges      `json:"severityAssessments"`
 	Domains                 []ProgramDomains      `json:"domains"`
+	BountyTables            []ProgramBountyTables `json:"bountyTables"`
 }
 
 type ProgramChanges struct {
@@ -71,265 +70,358 @@ type ProgramDomainsContent struct {
 	Description  string `json:"description"`
 }
 
-func (c *Client) GetProgramContentDiff(a Activity, field string) string {
+// GetProgram returns program details. Programs are cached for ProgramTTL
+// and revalidated with conditional requests afterwards, so a burst of updates
+// of one program costs a single request. The returned program is shared and
+// must not be modified.
+func (c *Client) GetProgram(ctx context.Context, company string, handle string) (*Program, error) {
+	return c.getProgram(ctx, company, handle, time.Time{})
+}
+
+// getProgram returns program details fetched at or after since (zero for
+// any cached version within TTL). Freshly fetched programs are stored as
+// snapshots.
+func (c *Client) getProgram(ctx context.Context, company string, handle string, since time.Time) (*Program, error) {
+	entry := c.programs.entry(company, handle)
+	if entry == nil {
+		// Client created without NewClient, no caching
+		entry = &programEntry{}
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	now := time.Now()
+	if entry.program != nil && now.Sub(entry.fetchedAt) < c.programTTL() && !entry.fetchedAt.Before(since) {
+		return entry.program, nil
+	}
 
-	ctx := c.HttpCtx
 	apiURL := fmt.Sprintf("%s/core/researcher/programs/%s/%s", c.ApiURL,
-		url.PathEscape(a.Companyhandle), url.PathEscape(a.Programhandle))
+		url.PathEscape(company), url.PathEscape(handle))
 
 	req, err := http.NewRequest("GET", apiURL, nil)
 	if err != nil {
-		return ""
+		return nil, err
 	}
 
 	req = req.WithContext(ctx)
 
+	etag := ""
+	if entry.program != nil {
+		etag = entry.etag
+	}
+
 	res := Program{}
 
-	if err := c.sendRequest(req, &res); err != nil {
-		return ""
+	etag, notModified, err := c.sendConditionalRequest(req, etag, &res)
+	if err != nil {
+		return nil, err
 	}
 
-	// Find content changes matching current activity
-
-	var changes []ProgramChanges
+	entry.fetchedAt = now
+	if notModified {
+		return entry.program, nil
+	}
+	entry.program = &res
+	entry.etag = etag
 
-	switch field {
-	case "OutScopes":
-		changes = res.OutScopes
-	case "InScopes":
-		changes = res.InScopes
-	case "Faqs":
-		changes = res.Faqs
-	case "SeverityAssessments":
-		changes = res.SeverityAssessments
-	default:
-		panic("Unknown field name")
+	if c.Snapshots != nil {
+		if _, err := c.Snapshots.Save(&res); err != nil {
+			log.Printf("Snapshot error: %s\n", err)
+		}
 	}
 
-	activityIdx := 0
-	activityCreated := a.CreatedAt / 1000 // Get rid of miliseconds
+	return &res, nil
+}
+
+// activityProgram returns program details including changes made by the activity
+func (c *Client) activityProgram(a Activity) (*Program, error) {
+	return c.getProgram(c.HttpCtx, a.Companyhandle, a.Programhandle, a.Time())
+}
 
-	for idx, chg := range changes {
+// historyDrift is the accepted difference (in seconds) between the activity
+// time and the creation time of the content version it refers to
+const historyDrift = 5
 
-		if (activityCreated) == chg.CreatedAt {
-			activityIdx = idx
-			break
-		}
+// matchHistory returns the index of the version created by the activity,
+// i.e. the newest one created at or before the activity (allowing for
+// historyDrift), and the index of the version preceding it. Indexes are -1
+// when there is no such version. Versions do not have to be ordered.
+func matchHistory(created []int64, activityCreated int64) (int, int) {
+	idx, prev := -1, -1
 
+	for i, t := range created {
+		if t > activityCreated+historyDrift {
+			continue
+		}
+		if idx < 0 || t > created[idx] {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return -1, -1
 	}
 
-	newContent := changes[activityIdx].Content.Content
-	oldContent := ""
+	for i, t := range created {
+		if t >= created[idx] {
+			continue
+		}
+		if prev < 0 || t > created[prev] {
+			prev = i
+		}
+	}
 
-	newDate := time.Unix(int64(activityCreated), 0).String()
-	oldDate := ""
+	return idx, prev
+}
 
-	if activityIdx > 0 {
-		oldContent = changes[activityIdx-1].Content.Content
-		oldDate = time.Unix(int64(changes[activityIdx-1].CreatedAt), 0).String()
+// newestVersion returns the index of the newest version (the last one of
+// versions created at the same time) or -1 without versions
+func newestVersion(created []int64) int {
+	idx := -1
+	for i, t := range created {
+		if idx < 0 || t >= created[idx] {
+			idx = i
+		}
 	}
+	return idx
+}
 
-	edits := myers.ComputeEdits(span.URIFromPath(oldDate), oldContent, newContent)
-	if len(edits) == 0 {
-		return "No changes"
-	}
-	content := fmt.Sprint(gotextdiff.ToUnified(newDate, oldDate, oldContent, edits))
-	if len(content) > 1800 {
-		content = "Message too long"
+// historyUnavailable returns diff explaining why the change cannot be shown
+func historyUnavailable(section string, a Activity, reason string) *ProgramDiff {
+	return &ProgramDiff{
+		Section:     section,
+		To:          a.Time(),
+		Unavailable: reason,
 	}
-	return content
 }
 
-func (c *Client) GetProgramRulesDiff(a Activity) string {
-
-	ctx := c.HttpCtx
-	apiURL := fmt.Sprintf("%s/core/researcher/programs/%s/%s", c.ApiURL,
-		url.PathEscape(a.Companyhandle), url.PathEscape(a.Programhandle))
+// GetProgramContentDiff returns the change of a text section (InScopes,
+// OutScopes, Faqs or SeverityAssessments) made by the activity
+func (c *Client) GetProgramContentDiff(a Activity, field string) (*ProgramDiff, error) {
 
-	req, err := http.NewRequest("GET", apiURL, nil)
+	res, err := c.activityProgram(a)
 	if err != nil {
-		return ""
+		return nil, err
 	}
 
-	req = req.WithContext(ctx)
-
-	res := Program{}
+	var changes []ProgramChanges
 
-	if err := c.sendRequest(req, &res); err != nil {
-		return ""
+	switch field {
+	case "OutScopes":
+		changes = res.OutScopes
+	case "InScopes":
+		changes = res.InScopes
+	case "Faqs":
+		changes = res.Faqs
+	case "SeverityAssessments":
+		changes = res.SeverityAssessments
+	default:
+		return nil, fmt.Errorf("unknown program content %q", field)
 	}
 
-	// Find content changes matching current activity
-
-	changes := res.RulesOfEngagement
+	created := make([]int64, len(changes))
+	contents := make([]string, len(changes))
+	for i, chg := range changes {
+		created[i] = chg.CreatedAt
+		contents[i] = chg.Content.Content
+	}
 
-	activityIdx := 0
-	activityCreated := a.CreatedAt / 1000 // Get rid of miliseconds
+	return textHistoryDiff(field, c.diffMode(), a, created, contents), nil
+}
 
-	for idx, chg := range changes {
-
-		if (activityCreated) == chg.CreatedAt {
-			activityIdx = idx
-			break
-		}
+// GetProgramRulesDiff returns the change of rules of engagement made by the activity
+func (c *Client) GetProgramRulesDiff(a Activity) (*ProgramDiff, error) {
 
+	res, err := c.activityProgram(a)
+	if err != nil {
+		return nil, err
 	}
 
-	newContent := changes[activityIdx].Content.Content.Description
-	oldContent := ""
+	created := make([]int64, len(res.RulesOfEngagement))
+	contents := make([]string, len(res.RulesOfEngagement))
+	for i, chg := range res.RulesOfEngagement {
+		created[i] = chg.CreatedAt
+		contents[i] = chg.Content.Content.Description
+	}
 
-	newDate := time.Unix(int64(activityCreated), 0).String()
-	oldDate := ""
+	return textHistoryDiff("RulesOfEngagement", c.diffMode(), a, created, contents), nil
+}
 
-	if activityIdx > 0 {
-		oldContent = changes[activityIdx-1].Content.Content.Description
-		oldDate = time.Unix(int64(changes[activityIdx-1].CreatedAt), 0).String()
+// textHistoryDiff returns diff of the text version created by the activity
+// against the previous version
+func textHistoryDiff(section string, mode string, a Activity, created []int64, contents []string) *ProgramDiff {
+	if len(created) == 0 {
+		return historyUnavailable(section, a, "program has no history of this content")
 	}
 
-	edits := myers.ComputeEdits(span.URIFromPath(oldDate), oldContent, newContent)
-	if len(edits) == 0 {
-		return "No changes"
+	idx, prev := matchHistory(created, a.CreatedAt/1000) // Versions are in seconds
+	if idx < 0 {
+		return historyUnavailable(section, a, "no version matches the activity time")
 	}
-	content := fmt.Sprint(gotextdiff.ToUnified(newDate, oldDate, oldContent, edits))
-	if len(content) > 1800 {
-		content = "Message too long"
+
+	if prev < 0 {
+		d := newTextDiff(section, DiffLines, time.Time{}, time.Unix(created[idx], 0), "", contents[idx])
+		d.First = true
+		return d
 	}
-	return content
 
+	return newTextDiff(section, mode, time.Unix(created[prev], 0), time.Unix(created[idx], 0), contents[prev], contents[idx])
 }
 
-func (c *Client) GetProgramDomainsDiff(a Activity) string {
-
-	ctx := c.HttpCtx
-	apiURL := fmt.Sprintf("%s/core/researcher/programs/%s/%s", c.ApiURL,
-		url.PathEscape(a.Companyhandle), url.PathEscape(a.Programhandle))
-
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return ""
+// GetProgramDescriptionDiff returns the change of the program description
+// made by the activity. The description has no history, so the previous one
+// is taken from the program fetched before the activity (cached or stored in
+// snapshots).
+func (c *Client) GetProgramDescriptionDiff(a Activity) (*ProgramDiff, error) {
+	old, from := c.previousProgram(a)
+
+	// The program is fetched even when the activity has the text, so the
+	// cache and snapshots are the base of the next change
+	new := a.Description
+	res, err := c.activityProgram(a)
+	switch {
+	case err != nil && new == "":
+		return nil, err
+	case err != nil:
+		log.Printf("Cannot fetch program %s/%s: %s\n", a.Companyhandle, a.Programhandle, err)
+	case new == "":
+		new = res.Description
 	}
 
-	req = req.WithContext(ctx)
+	if old == nil {
+		return historyUnavailable("Description", a, "no earlier version of the program (see -snapshots)"), nil
+	}
 
-	res := Program{}
+	return newTextDiff("Description", c.diffMode(), from, a.Time(), old.Description, new), nil
+}
 
-	if err := c.sendRequest(req, &res); err != nil {
-		return ""
+// previousProgram returns the newest program version fetched before the
+// activity and its fetch time
+func (c *Client) previousProgram(a Activity) (*Program, time.Time) {
+	if p, fetchedAt := c.programs.cached(a.Companyhandle, a.Programhandle); p != nil && fetchedAt.Before(a.Time()) {
+		return p, fetchedAt
 	}
 
-	// Find content changes matching current activity
-
-	changes := res.Domains
+	if c.Snapshots == nil {
+		return nil, time.Time{}
+	}
 
-	activityIdx := 0
-	activityCreated := a.CreatedAt / 1000 // Get rid of miliseconds
+	versions, err := c.Snapshots.Versions(a.Companyhandle, a.Programhandle)
+	if err != nil {
+		log.Printf("Snapshot error: %s\n", err)
+		return nil, time.Time{}
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		snap, err := c.Snapshots.Load(a.Companyhandle, a.Programhandle, versions[i])
+		if err != nil {
+			log.Printf("Snapshot error: %s\n", err)
+			continue
+		}
+		if snap.FetchedAt.Before(a.Time()) {
+			return &snap.Program, snap.FetchedAt
+		}
+	}
 
-	for idx, chg := range changes {
+	return nil, time.Time{}
+}
 
-		if (activityCreated) == chg.CreatedAt {
-			activityIdx = idx
-			break
-		}
+// GetProgramDomainsDiff returns domains added, removed and changed by the activity
+func (c *Client) GetProgramDomainsDiff(a Activity) (*ProgramDiff, error) {
 
+	res, err := c.activityProgram(a)
+	if err != nil {
+		return nil, err
 	}
 
-	prevProgramContent := changes[activityIdx-1].Content
-	nextProgramContent := changes[activityIdx].Content
-	newContent := ""
-	newDate := time.Unix(int64(activityCreated), 0).String()
-	oldDate := time.Unix(int64(changes[activityIdx-1].CreatedAt), 0).String()
+	return ProgramDomainsDiff(res, a, c.diffMode()), nil
+}
 
-	// Check all previous domains if something was removed in new domains
-	for _, pCont := range prevProgramContent {
-		old_id := pCont.Id
+// ProgramDomainsDiff returns domains added, removed and changed by the
+// activity, taken from the domain history of program p (e.g. a snapshot)
+func ProgramDomainsDiff(p *Program, a Activity, mode string) *ProgramDiff {
+	changes := p.Domains
+	if len(changes) == 0 {
+		return historyUnavailable("Domains", a, "program has no history of domains")
+	}
 
-		found := false
-		for _, newDom := range nextProgramContent {
-			if newDom.Id == old_id {
-				found = true
-				break
-			}
-		}
+	created := make([]int64, len(changes))
+	for i, chg := range changes {
+		created[i] = chg.CreatedAt
+	}
 
-		if found == false {
-			newContent += fmt.Sprintf("\n`%s` (%s) was removed!\n", pCont.Endpoint, c.GetEndpointType(pCont.Type))
-		}
+	idx, prev := matchHistory(created, a.CreatedAt/1000) // Versions are in seconds
+	if idx < 0 {
+		return historyUnavailable("Domains", a, "no version matches the activity time")
 	}
 
-	// Check all new domains if something was added or updated
-	for _, nCont := range nextProgramContent {
-		new_id := nCont.Id
+	if prev < 0 {
+		d := newDomainsDiff(mode, time.Time{}, time.Unix(created[idx], 0), nil, changes[idx].Content)
+		d.First = true
+		return d
+	}
 
-		found := false
-		foundIdx := 0
-		for idx, oldDom := range prevProgramContent {
-			if oldDom.Id == new_id {
-				found = true
-				foundIdx = idx
-			}
-		}
+	return newDomainsDiff(mode, time.Unix(created[prev], 0), time.Unix(created[idx], 0), changes[prev].Content, changes[idx].Content)
+}
 
-		if found == false {
-			newContent += fmt.Sprintf("\n`%s` (%s) was added with-in %s!\n", nCont.Endpoint, c.GetEndpointType(nCont.Type), c.GetEndpointTier(nCont.BountyTierId))
-
-		} else {
-			pCont := prevProgramContent[foundIdx]
-			if pCont.Endpoint != nCont.Endpoint || pCont.Type != nCont.Type || pCont.BountyTierId != nCont.BountyTierId || pCont.Description != nCont.Description {
-				newContent += fmt.Sprintf("\n`%s` (%s) was updated:\n", nCont.Endpoint, c.GetEndpointType(nCont.Type))
-				if pCont.Endpoint != nCont.Endpoint {
-					newContent += fmt.Sprintf(" - Endpoint: `%s` -> `%s`\n", pCont.Endpoint, nCont.Endpoint)
-				}
-				if pCont.Type != nCont.Type {
-					newContent += fmt.Sprintf(" - Type: `%s` -> `%s`\n", c.GetEndpointType(pCont.Type), c.GetEndpointType(nCont.Type))
-				}
-				if pCont.BountyTierId != nCont.BountyTierId {
-					newContent += fmt.Sprintf(" - Tier: `%s` -> `%s`\n", c.GetEndpointTier(pCont.BountyTierId), c.GetEndpointTier(nCont.BountyTierId))
-				}
-				if pCont.Description != nCont.Description {
-					// Add newlines (gotextdiff complains about it)
-					pCont.Description = fmt.Sprintf("%s\n", pCont.Description)
-					nCont.Description = fmt.Sprintf("%s\n", nCont.Description)
-
-					edits := myers.ComputeEdits(span.URIFromPath(oldDate), pCont.Description, nCont.Description)
-
-					diff := fmt.Sprint(gotextdiff.ToUnified(oldDate, newDate, pCont.Description, edits))
-					newContent += fmt.Sprintf(" - Description:\n```diff\n%s\n```\n", diff)
-				}
-
-			}
-		}
+// ProgramActivityDiff returns the diff of program content changed by the
+// activity or nil for activities without diff
+func (c *Client) ProgramActivityDiff(a Activity) (*ProgramDiff, error) {
+	switch a.Discriminator {
+	case 22:
+		return c.GetProgramDescriptionDiff(a)
+	case 23:
+		return c.GetProgramBountiesDiff(a)
+	case 24:
+		return c.GetProgramContentDiff(a, "InScopes")
+	case 25:
+		return c.GetProgramContentDiff(a, "OutScopes")
+	case 26:
+		return c.GetProgramContentDiff(a, "Faqs")
+	case 27:
+		return c.GetProgramDomainsDiff(a)
+	case 28:
+		return c.GetProgramRulesDiff(a)
+	case 29:
+		return c.GetProgramContentDiff(a, "SeverityAssessments")
+	}
+	return nil, nil
+}
 
+// eventDiff returns the diff already attached to the event or fetches it
+func (c *Client) eventDiff(e ActivityEvent) *ProgramDiff {
+	if e.Diff != nil {
+		return e.Diff
 	}
-	if len(newContent) > 1800 {
-		newContent = "Message too long"
+	d, err := c.ProgramActivityDiff(e.Activity)
+	if err != nil {
+		log.Printf("Cannot fetch program diff: %s\n", err)
 	}
-	return newContent
+	return d
+}
 
+var endpointTypes = []string{
+	"Dummy",
+	"URL",
+	"Android",
+	"iOS",
+	"IpRange",
+	"Device",
+	"Other",
 }
-func (c *Client) GetEndpointType(typeId int) string {
-	typeIds := []string{
-		"Dummy",
-		"URL",
-		"Android",
-		"iOS",
-		"IpRange",
-		"Device",
-		"Other",
-	}
 
-	return typeIds[typeId]
+var endpointTiers = []string{
+	"Dummy",
+	"No Bounty Tier",
+	"Tier 3",
+	"Tier 2",
+	"Tier 1",
+	"Out of scope",
 }
 
-func (c *Client) GetEndpointTier(tierId int) string {
-	tierIds := []string{
-		"Dummy",
-		"No Bounty Tier",
-		"Tier 3",
-		"Tier 2",
-		"Tier 1",
-		"Out of scope",
-	}
+func (c *Client) GetEndpointType(typeId int) string {
+	return lookupName(endpointTypes, typeId)
+}
 
-	return tierIds[tierId]
+func (c *Client) GetEndpointTier(tierId int) string {
+	return lookupName(endpointTiers, tierId)
 }
@@ -0,0 +1,82 @@
+package intitools
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchHistory(t *testing.T) {
+	tests := []struct {
+		name      string
+		created   []int64
+		activity  int64
+		idx, prev int
+	}{
+		{"empty history", nil, 1000, -1, -1},
+		{"first version", []int64{1000, 2000}, 1000, 0, -1},
+		{"latest version", []int64{1000, 2000}, 2000, 1, 0},
+		{"within drift", []int64{1000, 2000 + historyDrift}, 2000, 1, 0},
+		{"after drift", []int64{1000, 2000 + historyDrift + 1}, 2000, 0, -1},
+		{"no match", []int64{3000, 4000}, 2000, -1, -1},
+		{"unordered", []int64{3000, 1000, 2000}, 2500, 2, 1},
+	}
+
+	for _, tt := range tests {
+		idx, prev := matchHistory(tt.created, tt.activity)
+		if idx != tt.idx || prev != tt.prev {
+			t.Errorf("%s: matchHistory(%v, %d) = %d, %d, want %d, %d", tt.name, tt.created, tt.activity, idx, prev, tt.idx, tt.prev)
+		}
+	}
+}
+
+func TestTextHistoryDiff(t *testing.T) {
+	tests := []struct {
+		name        string
+		created     []int64
+		contents    []string
+		activity    int64 // Seconds
+		unavailable bool
+		first       bool
+		from, to    int64
+	}{
+		{"empty history", nil, nil, 1000, true, false, 0, 1000},
+		{"first version", []int64{1000, 2000}, []string{"a", "b"}, 1000, false, true, 0, 1000},
+		{"within drift", []int64{1000, 2000 + historyDrift}, []string{"a", "b"}, 2000, false, false, 1000, 2000 + historyDrift},
+		{"no match", []int64{3000}, []string{"a"}, 2000, true, false, 0, 2000},
+		{"unordered", []int64{3000, 1000, 2000}, []string{"c", "a", "b"}, 3000, false, false, 2000, 3000},
+	}
+
+	for _, tt := range tests {
+		a := Activity{CreatedAt: tt.activity * 1000}
+		d := textHistoryDiff("InScopes", DiffLines, a, tt.created, tt.contents)
+
+		if got := d.Unavailable != ""; got != tt.unavailable {
+			t.Errorf("%s: unavailable = %t (%q), want %t", tt.name, got, d.Unavailable, tt.unavailable)
+		}
+		if d.First != tt.first {
+			t.Errorf("%s: first = %t, want %t", tt.name, d.First, tt.first)
+		}
+		if tt.from == 0 && !d.From.IsZero() || tt.from != 0 && !d.From.Equal(time.Unix(tt.from, 0)) {
+			t.Errorf("%s: from = %s, want %d", tt.name, d.From, tt.from)
+		}
+		if !d.To.Equal(time.Unix(tt.to, 0)) {
+			t.Errorf("%s: to = %s, want %d", tt.name, d.To, tt.to)
+		}
+		if !tt.unavailable && len(d.Hunks) == 0 {
+			t.Errorf("%s: no hunks", tt.name)
+		}
+	}
+}
+
+func TestLatestContent(t *testing.T) {
+	changes := []ProgramChanges{{CreatedAt: 3000}, {CreatedAt: 1000}, {CreatedAt: 2000}}
+	changes[0].Content.Content = "newest"
+	changes[2].Content.Content = "older"
+
+	if got := latestContent(changes); got != "newest" {
+		t.Errorf("latestContent() = %q, want %q", got, "newest")
+	}
+	if got := latestContent(nil); got != "" {
+		t.Errorf("latestContent(nil) = %q, want empty", got)
+	}
+}
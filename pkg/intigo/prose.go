@@ -0,0 +1,301 @@
+package intitools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+)
+
+// Diff modes of program text (see Client.DiffMode)
+const (
+	DiffLines     = "lines"     // Unified diff of raw lines
+	DiffWords     = "words"     // Paragraphs compared word by word
+	DiffSentences = "sentences" // Paragraphs compared sentence by sentence
+)
+
+// DefaultDiffMode is used for program text when Client.DiffMode is empty
+const DefaultDiffMode = DiffWords
+
+// DiffModes lists supported diff modes
+var DiffModes = []string{DiffLines, DiffWords, DiffSentences}
+
+const (
+	proseContext   = 8    // Unchanged words shown around changed ones
+	maxProseTokens = 1000 // Larger token diffs replace the whole paragraph
+)
+
+var (
+	proseBlockPattern = regexp.MustCompile(`^\s{0,3}(#{1,6}|>|[-*+]|\d+[.)])\s+`)
+	proseLinkPattern  = regexp.MustCompile(`!?\[([^\]]*)\]\(([^)\s]*)[^)]*\)`)
+	proseEmphasis     = strings.NewReplacer("**", "", "__", "", "~~", "", "`", "")
+	proseItalic       = regexp.MustCompile(`\*([^\s*.][^*]*[^\s*]|[^\s*.])\*`) // Not wildcards like *.example.com
+)
+
+// DiffSegment is a run of words within a changed paragraph
+type DiffSegment struct {
+	Kind string `json:"kind"` // "+" inserted, "-" deleted, " " unchanged
+	Text string `json:"text"`
+}
+
+func (c *Client) diffMode() string {
+	if c.DiffMode != "" {
+		return c.DiffMode
+	}
+	return DefaultDiffMode
+}
+
+// ValidDiffMode reports whether mode is one of DiffModes
+func ValidDiffMode(mode string) bool {
+	for _, m := range DiffModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// proseParagraphs splits markdown text into paragraphs with markdown
+// formatting stripped and whitespace collapsed. Headings, list items and
+// quotes start a new paragraph.
+func proseParagraphs(text string) []string {
+	var paragraphs []string
+	var current []string
+
+	flush := func() {
+		if p := strings.Join(strings.Fields(strings.Join(current, " ")), " "); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n") {
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		if proseBlockPattern.MatchString(line) {
+			flush()
+			line = proseBlockPattern.ReplaceAllString(line, "")
+		}
+		current = append(current, stripMarkdown(line))
+	}
+	flush()
+
+	return paragraphs
+}
+
+// stripMarkdown removes inline markdown formatting, links keep their target
+func stripMarkdown(s string) string {
+	s = proseLinkPattern.ReplaceAllStringFunc(s, func(link string) string {
+		m := proseLinkPattern.FindStringSubmatch(link)
+		if m[1] == "" || m[1] == m[2] {
+			return m[2]
+		}
+		return m[1] + " (" + m[2] + ")"
+	})
+	return proseItalic.ReplaceAllString(proseEmphasis.Replace(s), "$1")
+}
+
+// sameProse reports whether texts differ only in whitespace or formatting
+func sameProse(old string, new string) bool {
+	o, n := proseParagraphs(old), proseParagraphs(new)
+	if len(o) != len(n) {
+		return false
+	}
+	for i := range o {
+		if o[i] != n[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// proseTokens splits a paragraph into words or sentences
+func proseTokens(paragraph string, mode string) []string {
+	words := strings.Fields(paragraph)
+	if mode != DiffSentences {
+		return words
+	}
+
+	var sentences []string
+	start := 0
+	for i, w := range words {
+		if strings.HasSuffix(w, ".") || strings.HasSuffix(w, "!") || strings.HasSuffix(w, "?") || i == len(words)-1 {
+			sentences = append(sentences, strings.Join(words[start:i+1], " "))
+			start = i + 1
+		}
+	}
+	return sentences
+}
+
+// diffTokens returns the edit script turning old into new, one entry per
+// token. Too large inputs are replaced as a whole.
+func diffTokens(old []string, new []string) []DiffSegment {
+	var ops []DiffSegment
+	if len(old)+len(new) > maxProseTokens {
+		for _, t := range old {
+			ops = append(ops, DiffSegment{Kind: "-", Text: t})
+		}
+		for _, t := range new {
+			ops = append(ops, DiffSegment{Kind: "+", Text: t})
+		}
+		return ops
+	}
+
+	// Tokens never contain newlines, so myers can compare them as lines
+	before, after := tokenLines(old), tokenLines(new)
+
+	i := 0
+	for _, e := range myers.ComputeEdits(span.URIFromPath("old"), before, after) {
+		from, to := e.Span.Start().Line()-1, e.Span.End().Line()-1
+		for ; i < from; i++ {
+			ops = append(ops, DiffSegment{Kind: " ", Text: old[i]})
+		}
+		for ; i < to; i++ {
+			ops = append(ops, DiffSegment{Kind: "-", Text: old[i]})
+		}
+		for _, t := range strings.SplitAfter(e.NewText, "\n") {
+			if t = strings.TrimSuffix(t, "\n"); t != "" {
+				ops = append(ops, DiffSegment{Kind: "+", Text: t})
+			}
+		}
+	}
+	for ; i < len(old); i++ {
+		ops = append(ops, DiffSegment{Kind: " ", Text: old[i]})
+	}
+
+	return ops
+}
+
+func tokenLines(tokens []string) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+	return strings.Join(tokens, "\n") + "\n"
+}
+
+// mergeSegments joins consecutive tokens of the same kind
+func mergeSegments(ops []DiffSegment) []DiffSegment {
+	var segments []DiffSegment
+	for _, op := range ops {
+		if k := len(segments) - 1; k >= 0 && segments[k].Kind == op.Kind {
+			segments[k].Text += " " + op.Text
+			continue
+		}
+		segments = append(segments, op)
+	}
+	return segments
+}
+
+// proseHunks compares paragraphs of the texts. Changed paragraphs are single
+// "~" lines with word (or sentence) segments, added and removed paragraphs
+// are "+" and "-" lines.
+func proseHunks(old string, new string, mode string) []DiffHunk {
+	if sameProse(old, new) {
+		return nil
+	}
+
+	var hunks []DiffHunk
+	var hunk *DiffHunk
+	var deleted, inserted []string
+	fromLine, toLine := 1, 1
+
+	flush := func() {
+		if len(deleted) == 0 && len(inserted) == 0 {
+			return
+		}
+		if hunk == nil {
+			hunks = append(hunks, DiffHunk{FromLine: fromLine - len(deleted), ToLine: toLine - len(inserted), Paragraphs: true})
+			hunk = &hunks[len(hunks)-1]
+		}
+
+		// Paragraphs replaced by similar ones (e.g. edited, split or
+		// joined) are compared token by token
+		if len(deleted) > 0 && len(inserted) > 0 {
+			var o, n []string
+			for _, p := range deleted {
+				o = append(o, proseTokens(p, mode)...)
+			}
+			for _, p := range inserted {
+				n = append(n, proseTokens(p, mode)...)
+			}
+			ops := diffTokens(o, n)
+			if similarProse(ops) {
+				hunk.Lines = append(hunk.Lines, DiffLine{Kind: "~", Text: strings.Join(inserted, " "), Segments: mergeSegments(ops)})
+				deleted, inserted = nil, nil
+				return
+			}
+		}
+
+		for _, p := range deleted {
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: "-", Text: p})
+		}
+		for _, p := range inserted {
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: "+", Text: p})
+		}
+		deleted, inserted = nil, nil
+	}
+
+	for _, op := range diffTokens(proseParagraphs(old), proseParagraphs(new)) {
+		switch op.Kind {
+		case "-":
+			deleted = append(deleted, op.Text)
+			fromLine++
+		case "+":
+			inserted = append(inserted, op.Text)
+			toLine++
+		default:
+			flush()
+			hunk = nil
+			fromLine++
+			toLine++
+		}
+	}
+	flush()
+
+	return hunks
+}
+
+// similarProse reports whether unchanged words number at least half of the
+// removed or inserted words, whichever are fewer. Mostly rewritten text is
+// shown as separate paragraphs instead.
+func similarProse(ops []DiffSegment) bool {
+	same, deleted, inserted := 0, 0, 0
+	for _, op := range ops {
+		words := len(strings.Fields(op.Text))
+		switch op.Kind {
+		case "-":
+			deleted += words
+		case "+":
+			inserted += words
+		default:
+			same += words
+		}
+	}
+	if inserted < deleted {
+		deleted = inserted
+	}
+	return same > 0 && 2*same >= deleted
+}
+
+// elide shortens unchanged text to the words next to changes. first and
+// last tell whether the segment starts or ends the paragraph.
+func elide(text string, first bool, last bool) string {
+	words := strings.Fields(text)
+	if len(words) <= 2*proseContext {
+		return text
+	}
+
+	switch {
+	case first && last:
+		return text
+	case first:
+		return "… " + strings.Join(words[len(words)-proseContext:], " ")
+	case last:
+		return strings.Join(words[:proseContext], " ") + " …"
+	}
+	return fmt.Sprintf("%s … %s", strings.Join(words[:proseContext], " "), strings.Join(words[len(words)-proseContext:], " "))
+}
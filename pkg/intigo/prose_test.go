@@ -0,0 +1,83 @@
+package intitools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffTokens(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{"equal", "a b c", "a b c", " a  b  c"},
+		{"empty old", "", "a b", "+a +b"},
+		{"empty new", "a b", "", "-a -b"},
+		{"replaced word", "a b c", "a x c", " a -b +x  c"},
+		{"inserted words", "a c", "a b b c", " a +b +b  c"},
+		{"deleted words", "a b c d", "a d", " a -b -c  d"},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, op := range diffTokens(strings.Fields(tt.old), strings.Fields(tt.new)) {
+			got = append(got, op.Kind+op.Text)
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("%s: diffTokens() = %q, want %q", tt.name, strings.Join(got, " "), tt.want)
+		}
+	}
+}
+
+func TestDiffTokensLimit(t *testing.T) {
+	old := strings.Fields(strings.Repeat("a ", maxProseTokens))
+	new := append([]string{"b"}, old...)
+
+	ops := diffTokens(old, new)
+	if len(ops) != len(old)+len(new) || ops[0].Kind != "-" || ops[len(ops)-1].Kind != "+" {
+		t.Errorf("diffTokens() over limit = %d ops, want whole replacement", len(ops))
+	}
+}
+
+func TestProseHunks(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		mode     string
+		want     []string // Lines as kind + text, segments of "~" lines in brackets
+	}{
+		{"formatting only", "Test **only** the *main* application.\n\n* [docs](https://example.com)",
+			"Test only the  main application.\n\n- [docs](https://example.com)", DiffWords, nil},
+		{"whitespace only", "Out of scope:\r\nself XSS", "Out of scope: self XSS\n", DiffWords, nil},
+		{"word changed", "Rewards are paid within 30 days.", "Rewards are paid within 14 days.", DiffWords,
+			[]string{"~[ Rewards are paid within|-30|+14| days.]"}},
+		{"sentence changed", "Be nice. Do not use scanners. Report fast.", "Be nice. Use scanners slowly. Report fast.", DiffSentences,
+			[]string{"~[ Be nice.|-Do not use scanners.|+Use scanners slowly.| Report fast.]"}},
+		{"paragraph added", "Intro.\n\nRules.", "Intro.\n\nNew section.\n\nRules.", DiffWords,
+			[]string{"+New section."}},
+		{"paragraph rewritten", "Old terms apply here.", "Completely different wording now.", DiffWords,
+			[]string{"-Old terms apply here.", "+Completely different wording now."}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, h := range proseHunks(tt.old, tt.new, tt.mode) {
+			for _, l := range h.Lines {
+				if l.Kind != "~" {
+					got = append(got, l.Kind+l.Text)
+					continue
+				}
+				var segments []string
+				for _, s := range l.Segments {
+					segments = append(segments, s.Kind+s.Text)
+				}
+				got = append(got, "~["+strings.Join(segments, "|")+"]")
+			}
+		}
+
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: proseHunks() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
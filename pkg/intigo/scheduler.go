@@ -0,0 +1,200 @@
+package intitools
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const (
+	defaultJitter    = 0.1
+	defaultIdleAfter = 30 * time.Minute
+)
+
+// Scheduler computes delays between polls of the activity feed.
+//
+// After new activities it polls every MinInterval and slows down to Interval
+// with every empty poll. When there was no activity for IdleAfter or during
+// off-hours it slows down up to MaxInterval. Errors back off exponentially
+// from Interval up to MaxInterval. Every delay gets random jitter.
+type Scheduler struct {
+	Interval    time.Duration    // Base polling interval
+	MinInterval time.Duration    // Interval right after new activity (default Interval/2)
+	MaxInterval time.Duration    // Upper limit when idle, off-hours or failing (default 5*Interval)
+	IdleAfter   time.Duration    // Time without activity after which feed is considered idle (default 30m)
+	Jitter      float64          // Random jitter as a fraction of the delay (default 0.1, negative disables)
+	OffHours    []TimeRange      // Daily ranges polled with MaxInterval
+	Location    *time.Location   // Time zone of OffHours (default local)
+	Rand        *rand.Rand       // Source of jitter (default seeded with current time)
+	Clock       func() time.Time // Current time (default time.Now)
+
+	idle         int // Consecutive empty polls
+	errors       int // Consecutive failed polls
+	lastActivity time.Time
+}
+
+// TimeRange is a daily time range, e.g. 22:00-07:00. End may be before Start
+// for ranges spanning midnight.
+type TimeRange struct {
+	Start time.Duration // Offset from midnight
+	End   time.Duration
+}
+
+// NewScheduler returns a scheduler with defaults derived from interval
+func NewScheduler(interval time.Duration) *Scheduler {
+	s := &Scheduler{Interval: interval}
+	s.setDefaults()
+	return s
+}
+
+func (s *Scheduler) setDefaults() {
+	if s.Interval <= 0 {
+		s.Interval = defaultWatchInterval
+	}
+	if s.MinInterval <= 0 || s.MinInterval > s.Interval {
+		s.MinInterval = s.Interval / 2
+	}
+	if s.MaxInterval < s.Interval {
+		s.MaxInterval = 5 * s.Interval
+	}
+	if s.IdleAfter <= 0 {
+		s.IdleAfter = defaultIdleAfter
+	}
+	if s.Jitter == 0 || s.Jitter >= 1 {
+		s.Jitter = defaultJitter
+	}
+	if s.Location == nil {
+		s.Location = time.Local
+	}
+	if s.Rand == nil {
+		s.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	if s.lastActivity.IsZero() {
+		s.lastActivity = s.now()
+	}
+}
+
+func (s *Scheduler) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
+	}
+	return s.Clock()
+}
+
+// Next returns the delay before the next poll given the result of the last one
+func (s *Scheduler) Next(now time.Time, found int, err error) time.Duration {
+	s.setDefaults()
+
+	var d time.Duration
+
+	switch {
+	case err != nil:
+		s.errors++
+		d = s.Interval << uint(minInt(s.errors, 10))
+
+	case found > 0:
+		s.errors = 0
+		s.idle = 0
+		s.lastActivity = now
+		d = s.MinInterval
+
+	default:
+		s.errors = 0
+		s.idle++
+		d = s.MinInterval << uint(minInt(s.idle, 10))
+		if d > s.Interval {
+			d = s.Interval
+		}
+		// No activity for a long time, keep slowing down
+		if idleFor := now.Sub(s.lastActivity); idleFor >= s.IdleAfter {
+			d = s.Interval << uint(minInt(int(idleFor/s.IdleAfter), 10))
+		}
+	}
+
+	if s.inOffHours(now) {
+		d = s.MaxInterval
+	}
+
+	if d > s.MaxInterval {
+		d = s.MaxInterval
+	}
+
+	return s.jitter(d)
+}
+
+func (s *Scheduler) jitter(d time.Duration) time.Duration {
+	if s.Jitter < 0 {
+		return d
+	}
+	delta := float64(d) * s.Jitter * (2*s.Rand.Float64() - 1)
+	return d + time.Duration(delta)
+}
+
+func (s *Scheduler) inOffHours(now time.Time) bool {
+	if len(s.OffHours) == 0 {
+		return false
+	}
+
+	t := now.In(s.Location)
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+
+	for _, r := range s.OffHours {
+		if r.contains(offset) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r TimeRange) contains(offset time.Duration) bool {
+	if r.Start <= r.End {
+		return offset >= r.Start && offset < r.End
+	}
+	return offset >= r.Start || offset < r.End
+}
+
+// ParseTimeRanges parses comma separated daily ranges, e.g. "22:00-07:00,12:00-13:00"
+func ParseTimeRanges(s string) ([]TimeRange, error) {
+	var ranges []TimeRange
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		bounds := strings.Split(part, "-")
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("invalid time range %q", part)
+		}
+
+		start, err := parseClock(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(bounds[1])
+		if err != nil {
+			return nil, err
+		}
+
+		ranges = append(ranges, TimeRange{Start: start, End: end})
+	}
+
+	return ranges, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,92 @@
+package intitools
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+var schedulerStart = time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+
+func newTestScheduler(jitter float64, seed int64) *Scheduler {
+	s := &Scheduler{
+		Interval: time.Minute,
+		Jitter:   jitter,
+		Location: time.UTC,
+		Rand:     rand.New(rand.NewSource(seed)),
+		Clock:    func() time.Time { return schedulerStart },
+	}
+	s.setDefaults()
+	return s
+}
+
+type schedulerPoll struct {
+	after time.Duration // Time since start
+	found int
+	err   error
+	want  time.Duration
+}
+
+func TestSchedulerNext(t *testing.T) {
+	errPoll := errors.New("poll failed")
+
+	tests := []struct {
+		name     string
+		offHours []TimeRange
+		polls    []schedulerPoll
+	}{
+		{"empty polls", nil, []schedulerPoll{
+			{time.Minute, 0, nil, time.Minute},
+			{2 * time.Minute, 0, nil, time.Minute},
+		}},
+		{"idle growth", nil, []schedulerPoll{
+			{30 * time.Minute, 0, nil, 2 * time.Minute},
+			{60 * time.Minute, 0, nil, 4 * time.Minute},
+			{90 * time.Minute, 0, nil, 5 * time.Minute},
+		}},
+		{"error backoff", nil, []schedulerPoll{
+			{time.Minute, 0, errPoll, 2 * time.Minute},
+			{2 * time.Minute, 0, errPoll, 4 * time.Minute},
+			{3 * time.Minute, 0, errPoll, 5 * time.Minute},
+			{4 * time.Minute, 0, errPoll, 5 * time.Minute},
+		}},
+		{"reset on activity", nil, []schedulerPoll{
+			{time.Minute, 0, errPoll, 2 * time.Minute},
+			{2 * time.Minute, 0, errPoll, 4 * time.Minute},
+			{60 * time.Minute, 3, nil, 30 * time.Second},
+			{61 * time.Minute, 0, nil, time.Minute},
+			{62 * time.Minute, 0, errPoll, 2 * time.Minute},
+		}},
+		{"off-hours", []TimeRange{{Start: 11 * time.Hour, End: 13 * time.Hour}}, []schedulerPoll{
+			{time.Minute, 1, nil, 5 * time.Minute},
+			{61 * time.Minute, 1, nil, 30 * time.Second},
+		}},
+	}
+
+	for _, tt := range tests {
+		s := newTestScheduler(-1, 1)
+		s.OffHours = tt.offHours
+
+		for i, p := range tt.polls {
+			if got := s.Next(schedulerStart.Add(p.after), p.found, p.err); got != p.want {
+				t.Errorf("%s: poll %d = %s, want %s", tt.name, i, got, p.want)
+			}
+		}
+	}
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	a := newTestScheduler(0.2, 42)
+	b := newTestScheduler(0.2, 42)
+
+	for i := 0; i < 1000; i++ {
+		got := a.Next(schedulerStart, 1, nil)
+		if got < 24*time.Second || got > 36*time.Second {
+			t.Fatalf("poll %d = %s, want within 20%% of 30s", i, got)
+		}
+		if other := b.Next(schedulerStart, 1, nil); other != got {
+			t.Fatalf("poll %d = %s and %s with the same seed", i, got, other)
+		}
+	}
+}
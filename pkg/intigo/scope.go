@@ -0,0 +1,293 @@
+package intitools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// outOfScopeTier is the bounty tier of domains listed as out of scope
+const outOfScopeTier = 5
+
+// ScopeFormats lists formats supported by ExportScope
+var ScopeFormats = []string{"hosts", "wildcards", "urls", "cidrs", "burp", "json"}
+
+// ScopeAsset is a single domain (endpoint) of a program
+type ScopeAsset struct {
+	Endpoint    string  `json:"endpoint"`
+	Type        string  `json:"type"`
+	Tier        string  `json:"tier"`
+	InScope     bool    `json:"inScope"`
+	Description string  `json:"description,omitempty"`
+	Assets      []Asset `json:"assets"` // Parsed endpoint
+}
+
+// ProgramScope is the current scope of a program
+type ProgramScope struct {
+	CompanyHandle string       `json:"companyHandle"`
+	Handle        string       `json:"handle"`
+	Name          string       `json:"name"`
+	Assets        []ScopeAsset `json:"assets"`
+	InScope       string       `json:"inScope,omitempty"`    // In scope text
+	OutOfScope    string       `json:"outOfScope,omitempty"` // Out of scope text
+}
+
+// ScopeFilter selects exported assets. Empty lists match everything.
+type ScopeFilter struct {
+	Types      []string // Endpoint types, e.g. URL or IpRange (case-insensitive)
+	Tiers      []string // Bounty tiers, e.g. "Tier 1" or just "1" (case-insensitive)
+	Kinds      []string // Parsed asset kinds, e.g. wildcard or iprange (see ParseAsset)
+	OutOfScope bool     // Export out of scope assets instead of in scope ones
+}
+
+// NewProgramScope returns the scope of the latest program version
+func NewProgramScope(p *Program) *ProgramScope {
+	s := &ProgramScope{
+		CompanyHandle: p.CompanyHandle,
+		Handle:        p.Handle,
+		Name:          p.Name,
+		InScope:       latestContent(p.InScopes),
+		OutOfScope:    latestContent(p.OutScopes),
+	}
+
+	for _, d := range latestDomains(p.Domains) {
+		s.Assets = append(s.Assets, newScopeAsset(d))
+	}
+
+	return s
+}
+
+func newScopeAsset(d ProgramDomainsContent) ScopeAsset {
+	return ScopeAsset{
+		Endpoint:    strings.TrimSpace(d.Endpoint),
+		Type:        lookupName(endpointTypes, d.Type),
+		Tier:        lookupName(endpointTiers, d.BountyTierId),
+		InScope:     d.BountyTierId != outOfScopeTier,
+		Description: d.Description,
+		Assets:      ParseDomain(d),
+	}
+}
+
+func (f ScopeFilter) match(a ScopeAsset) bool {
+	if a.InScope == f.OutOfScope {
+		return false
+	}
+	if len(f.Types) > 0 && !containsFold(f.Types, a.Type) {
+		return false
+	}
+	if len(f.Tiers) > 0 && !containsFold(f.Tiers, a.Tier) && !containsFold(f.Tiers, strings.TrimPrefix(a.Tier, "Tier ")) {
+		return false
+	}
+	if len(f.Kinds) > 0 {
+		for _, kind := range AssetKinds(a.Assets) {
+			if containsFold(f.Kinds, kind) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Filter returns assets matching the filter
+func (s *ProgramScope) Filter(f ScopeFilter) []ScopeAsset {
+	var assets []ScopeAsset
+	for _, a := range s.Assets {
+		if f.match(a) {
+			assets = append(assets, a)
+		}
+	}
+	return assets
+}
+
+// ExportScope writes assets of the programs matching the filter in format
+// (see ScopeFormats). Plain text formats list every value once and skip
+// hosts covered by a listed wildcard.
+func ExportScope(w io.Writer, scopes []*ProgramScope, format string, f ScopeFilter) error {
+	switch format {
+	case "json":
+		return exportScopeJSON(w, scopes, f)
+	case "burp":
+		return exportScopeBurp(w, scopes, f)
+	}
+
+	var convert func(Asset) []string
+	switch format {
+	case "hosts":
+		convert = func(a Asset) []string {
+			// Host of wildcard URLs (e.g. *.example.com/api) is the root domain, which is not in scope
+			if (a.Kind == AssetHost || a.Kind == AssetURL) && !strings.Contains(a.Value, "*") {
+				return []string{a.Host}
+			}
+			return nil
+		}
+	case "wildcards":
+		convert = func(a Asset) []string {
+			if a.Kind == AssetWildcard {
+				return []string{a.Host}
+			}
+			return nil
+		}
+	case "urls":
+		convert = func(a Asset) []string {
+			switch {
+			case a.Kind == AssetURL && !strings.Contains(a.Value, "*"):
+				return []string{a.Value}
+			case a.Kind == AssetHost && !strings.Contains(a.Value, "*"):
+				return []string{"https://" + a.Value}
+			}
+			return nil
+		}
+	case "cidrs":
+		convert = func(a Asset) []string { return a.CIDRs }
+	default:
+		return fmt.Errorf("unknown scope format %q", format)
+	}
+
+	var assets []Asset
+	for _, s := range scopes {
+		for _, a := range s.Filter(f) {
+			assets = append(assets, a.Assets...)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, a := range DedupAssets(assets) {
+		for _, v := range convert(a) {
+			if seen[v] {
+				continue
+			}
+			seen[v] = true
+			if _, err := fmt.Fprintln(w, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func singleCIDR(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return v4.String() + "/32"
+	}
+	return ip.String() + "/128"
+}
+
+// rangeCIDRs splits an IPv4 range into CIDRs
+func rangeCIDRs(first net.IP, last net.IP) []string {
+	if first == nil || last == nil || first.To4() == nil || last.To4() == nil {
+		return nil
+	}
+
+	start := uint64(ipv4ToInt(first.To4()))
+	end := uint64(ipv4ToInt(last.To4()))
+
+	var cidrs []string
+	for start <= end {
+		// Largest block aligned at start that fits in the range
+		size := uint64(1)
+		bits := 32
+		for bits > 0 && start%(size*2) == 0 && start+size*2-1 <= end {
+			size *= 2
+			bits--
+		}
+		ip := net.IPv4(byte(start>>24), byte(start>>16), byte(start>>8), byte(start))
+		cidrs = append(cidrs, ip.String()+"/"+strconv.Itoa(bits))
+		start += size
+	}
+
+	return cidrs
+}
+
+func ipv4ToInt(ip net.IP) uint32 {
+	return uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+}
+
+func exportScopeJSON(w io.Writer, scopes []*ProgramScope, f ScopeFilter) error {
+	filtered := make([]ProgramScope, 0, len(scopes))
+	for _, s := range scopes {
+		c := *s
+		c.Assets = s.Filter(f)
+		filtered = append(filtered, c)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(filtered)
+}
+
+// burpScope is the target scope of Burp Suite project options (advanced mode)
+type burpScope struct {
+	Target struct {
+		Scope struct {
+			AdvancedMode bool        `json:"advanced_mode"`
+			Include      []burpEntry `json:"include"`
+			Exclude      []burpEntry `json:"exclude"`
+		} `json:"scope"`
+	} `json:"target"`
+}
+
+type burpEntry struct {
+	Enabled  bool   `json:"enabled"`
+	Protocol string `json:"protocol"`
+	Host     string `json:"host"`
+	Port     string `json:"port,omitempty"`
+	File     string `json:"file,omitempty"`
+}
+
+// burpHostRegexp returns host regexp of a network asset ("*." matches any subdomain)
+func burpHostRegexp(a Asset) string {
+	switch a.Kind {
+	case AssetWildcard:
+		return `^(.*\.)?` + regexp.QuoteMeta(a.Host) + `$`
+	case AssetHost, AssetURL:
+		host := a.Host
+		if strings.HasPrefix(a.Value, "*.") || strings.Contains(a.Value, "://*.") {
+			return `^(.*\.)?` + regexp.QuoteMeta(host) + `$`
+		}
+		return "^" + regexp.QuoteMeta(host) + "$"
+	}
+	return ""
+}
+
+// exportScopeBurp writes Burp Suite target scope with in scope URL assets
+// included and out of scope ones excluded (ScopeFilter.OutOfScope is ignored)
+func exportScopeBurp(w io.Writer, scopes []*ProgramScope, f ScopeFilter) error {
+	var doc burpScope
+	doc.Target.Scope.AdvancedMode = true
+	doc.Target.Scope.Include = []burpEntry{}
+	doc.Target.Scope.Exclude = []burpEntry{}
+
+	seen := make(map[string]bool)
+	for _, s := range scopes {
+		for _, outOfScope := range []bool{false, true} {
+			f.OutOfScope = outOfScope
+			for _, sa := range s.Filter(f) {
+				for _, a := range sa.Assets {
+					host := burpHostRegexp(a)
+					key := fmt.Sprintf("%t/%s", outOfScope, host)
+					if host == "" || seen[key] {
+						continue
+					}
+					seen[key] = true
+
+					entry := burpEntry{Enabled: true, Protocol: "any", Host: host, File: "^/.*"}
+					if outOfScope {
+						doc.Target.Scope.Exclude = append(doc.Target.Scope.Exclude, entry)
+					} else {
+						doc.Target.Scope.Include = append(doc.Target.Scope.Include, entry)
+					}
+				}
+			}
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
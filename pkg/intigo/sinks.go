@@ -0,0 +1,176 @@
+package intitools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrEmptyMessage is returned by formatters for events that should not be sent
+// (e.g. our own submission messages)
+var ErrEmptyMessage = errors.New("empty message")
+
+// SlackSink formats events as Slack messages and sends them to a webhook.
+// Long content is sent in follow-up messages.
+type SlackSink struct {
+	Client     *Client
+	WebhookURL string
+}
+
+// slackDelivery is the formatted event passed from SlackSink.Format to
+// SlackSink.Deliver
+type slackDelivery struct {
+	Message   json.RawMessage   `json:"message"`
+	FollowUps []json.RawMessage `json:"followUps,omitempty"`
+}
+
+func (s *SlackSink) Format(e ActivityEvent) (string, error) {
+	message, followUps, err := s.Client.SlackFormatEventFull(e)
+	if err != nil {
+		return "", err
+	}
+
+	d := slackDelivery{Message: json.RawMessage(message)}
+	for _, m := range followUps {
+		d.FollowUps = append(d.FollowUps, json.RawMessage(m))
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func (s *SlackSink) Deliver(ctx context.Context, message string) error {
+	var d slackDelivery
+	if err := json.Unmarshal([]byte(message), &d); err != nil {
+		return err
+	}
+
+	for _, m := range append([]json.RawMessage{d.Message}, d.FollowUps...) {
+		if err := s.Client.SlackSendTo(s.WebhookURL, string(m)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DiscordSink formats events as Discord embeds and sends them to a webhook.
+// Long content is attached as files.
+type DiscordSink struct {
+	Client     *Client
+	WebhookURL string
+}
+
+// discordDelivery is the formatted event passed from DiscordSink.Format to
+// DiscordSink.Deliver
+type discordDelivery struct {
+	Message json.RawMessage `json:"message"`
+	Files   []DiscordFile   `json:"files,omitempty"`
+}
+
+func (s *DiscordSink) Format(e ActivityEvent) (string, error) {
+	message, files, err := s.Client.DiscordFormatEventFull(e)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(discordDelivery{Message: json.RawMessage(message), Files: files})
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func (s *DiscordSink) Deliver(ctx context.Context, message string) error {
+	var d discordDelivery
+	if err := json.Unmarshal([]byte(message), &d); err != nil {
+		return err
+	}
+
+	return s.Client.DiscordSendFiles(ctx, s.WebhookURL, string(d.Message), d.Files)
+}
+
+// JSONSink posts events as JSON documents, e.g. to a custom webhook receiver.
+// Program diffs are sent in full as structured data.
+type JSONSink struct {
+	Client *Client
+	URL    string
+}
+
+// jsonEvent is the document posted by JSONSink
+type jsonEvent struct {
+	Type          string          `json:"type"`
+	Discriminator int             `json:"discriminator"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	ProgramHandle string          `json:"programHandle,omitempty"`
+	CompanyHandle string          `json:"companyHandle,omitempty"`
+	Submission    *Submission     `json:"submission,omitempty"`
+	Diff          *ProgramDiff    `json:"diff,omitempty"`
+	Conflicts     ScopeConflicts  `json:"conflicts,omitempty"`
+	Activity      json.RawMessage `json:"activity"`
+}
+
+func (s *JSONSink) Format(e ActivityEvent) (string, error) {
+	a := e.Activity
+
+	raw := a.Raw
+	if len(raw) == 0 {
+		var err error
+		if raw, err = json.Marshal(a); err != nil {
+			return "", err
+		}
+	}
+
+	doc := jsonEvent{
+		Type:          ActivityTypeName(a.Discriminator),
+		Discriminator: a.Discriminator,
+		CreatedAt:     a.Time().UTC(),
+		ProgramHandle: a.Programhandle,
+		CompanyHandle: a.Companyhandle,
+		Submission:    e.Submission,
+		Diff:          e.Diff,
+		Activity:      raw,
+	}
+	if doc.Diff == nil {
+		doc.Diff = s.Client.eventDiff(e)
+	}
+	doc.Conflicts = s.Client.eventConflicts(e)
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func (s *JSONSink) Deliver(ctx context.Context, message string) error {
+	req, err := http.NewRequest("POST", s.URL, strings.NewReader(message))
+	if err != nil {
+		return err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.Client.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("cannot send message. Error code: %d", res.StatusCode)
+	}
+
+	return nil
+}
@@ -7,6 +7,14 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
+)
+
+const (
+	slackDiffLength      = 2500 // Rendered diffs (with scope conflicts) fit in a section block (3000 characters)
+	slackConflictsLength = 1000 // Scope conflicts, counted in slackDiffLength
+	slackExcerptLength   = 500  // Quoted text of descriptions and program updates
+	slackFollowUpLength  = 2500 // Parts of long content sent as follow-up messages
 )
 
 type slackMessage struct {
@@ -16,9 +24,10 @@ type slackMessage struct {
 }
 
 type slackBlock struct {
-	Type      string              `json:"type"`
-	Text      slackBlockText      `json:"text"`
-	Accessory slackBlockAccessory `json:"accessory"`
+	Type      string               `json:"type"`
+	Text      *slackBlockText      `json:"text,omitempty"`
+	Accessory *slackBlockAccessory `json:"accessory,omitempty"`
+	Elements  []slackBlockText     `json:"elements,omitempty"`
 }
 
 type slackBlockText struct {
@@ -33,8 +42,10 @@ type slackBlockAccessory struct {
 }
 
 func (c *Client) SlackSend(message string) error {
-	webhookURL := c.WebhookURL
+	return c.SlackSendTo(c.WebhookURL, message)
+}
 
+func (c *Client) SlackSendTo(webhookURL string, message string) error {
 	if webhookURL == "" {
 		return fmt.Errorf("Webhook not defined.")
 	}
@@ -62,15 +73,29 @@ func (c *Client) SlackSend(message string) error {
 }
 
 func (c *Client) SlackFormatActivity(a Activity) (string, error) {
+	return c.SlackFormatEvent(ActivityEvent{Activity: a})
+}
 
+// SlackFormatEvent formats activity event using details added by enrichment
+func (c *Client) SlackFormatEvent(e ActivityEvent) (string, error) {
+	message, _, err := c.SlackFormatEventFull(e)
+	return message, err
+}
+
+// SlackFormatEventFull formats activity event like SlackFormatEvent. Content
+// shortened in the message (long descriptions, diffs and program updates) is
+// returned in full as follow-up messages to send after it.
+func (c *Client) SlackFormatEventFull(e ActivityEvent) (string, []string, error) {
+
+	a := e.Activity
 	var message string
+	var full string // Complete content sent in follow-up messages when the message shows a part only
 
-	submissionLink := fmt.Sprintf("*%s* <https://app.intigriti.com/researcher/submissions/%s/%s|%s>",
-		url.PathEscape(a.Programname), url.PathEscape(a.Programid), a.Submissioncode, a.Submissiontitle)
-	programLink := fmt.Sprintf("<https://app.intigriti.com/researcher/programs/%s/%s/detail|%s>",
-		url.PathEscape(a.Companyhandle), url.PathEscape(a.Programhandle), a.Programname)
+	submissionLink := fmt.Sprintf("*%s* <%s|%s>",
+		url.PathEscape(a.Programname), c.submissionURL(a.Programid, a.Submissioncode), a.Submissiontitle)
+	programLink := fmt.Sprintf("<%s|%s>", c.programURL(a.Companyhandle, a.Programhandle), a.Programname)
 
-	iconUrl := fmt.Sprintf("https://app.intigriti.com/api/file/api/file/%s", a.Programlogoid)
+	iconUrl := fmt.Sprintf("%s/file/api/file/%s", c.ApiURL, a.Programlogoid)
 
 	switch d := a.Discriminator; d {
 
@@ -78,36 +103,56 @@ func (c *Client) SlackFormatActivity(a Activity) (string, error) {
 		userRole := a.User.Role
 		// Do not send notifications about our own messages
 		if userRole == "RESEARCHER" {
-			return "", fmt.Errorf("empty message")
+			return "", nil, ErrEmptyMessage
 		}
 
 		message = fmt.Sprintf("%s\nNew *message* from *%s* (%s)",
 			submissionLink, a.User.Username, userRole)
+		text, attachments := messageExcerpt(e.Message)
+		if text != "" {
+			message += "\n" + slackQuote(text)
+		}
+		if attachments != "" {
+			message += "\n:paperclip: " + slackEscaper.Replace(attachments)
+		}
 
 	//	2	Submission 	- Status change
 	case 2:
-		newState := c.GetSubmissionState(a.Newstate.Status)
-		// If status is Closed add reason
-		if a.Newstate.Status == 4 {
-			newState += " as " + c.GetClosedState(a.Newstate.Closereason)
+		// Closed status includes the reason
+		t := a.Transition()
+		if t.From != "" {
+			message = fmt.Sprintf("%s\nThe *status* changed `%s` → `%s`", submissionLink, t.From, t.To)
+		} else {
+			message = fmt.Sprintf("%s\nThe *status* changed to `%s`", submissionLink, t.To)
+		}
+		if d := e.Duplicate; d != nil {
+			message += fmt.Sprintf("\nDuplicate of <%s|%s>%s", c.submissionURL(d.ProgramId, d.Code), d.Code, c.duplicateDetails(d))
 		}
-
-		message = fmt.Sprintf("%s\nThe *status* changed to `%s`", submissionLink, newState)
 
 	//	3	Submission 	- Change Severity
 	case 3:
-		message = fmt.Sprintf("%s\nThe *severity* changed to `%s`", submissionLink, c.GetSeverity(a.Newseverityid))
+		if e.PreviousSeverity > 0 {
+			message = fmt.Sprintf("%s\nThe *severity* changed `%s` → `%s`", submissionLink, c.GetSeverity(e.PreviousSeverity), c.GetSeverity(a.Newseverityid))
+		} else {
+			message = fmt.Sprintf("%s\nThe *severity* changed to `%s`", submissionLink, c.GetSeverity(a.Newseverityid))
+		}
 
 	//	5 	Submission 	- Payout
 	case 5:
-		message = fmt.Sprintf("%s\nNew payout *%s %.f* :partying_face:", submissionLink, a.NewPayoutAmount.Currency, a.NewPayoutAmount.Value)
+		message = fmt.Sprintf("%s\nNew payout *%s* :partying_face:", submissionLink, c.payoutAmount(e))
 
 	//	7 	Submission 	- Change vulnerable endpoint
 	case 7:
 		message = fmt.Sprintf("%s\nThe *endpoint / vulnerable component* changed", submissionLink)
+		if a.Newendpoint != "" {
+			message += fmt.Sprintf(" to `%s`", truncate(a.Newendpoint, 200))
+		}
 	//	8 	Submission 	- User changed vulnerability type
 	case 8:
 		message = fmt.Sprintf("%s\n*%s* changed *vulnerability type*", submissionLink, a.UserName)
+		if e.Submission != nil && e.Submission.Type.Name != "" {
+			message += fmt.Sprintf(" to `%s`", e.Submission.Type.Name)
+		}
 	//	9 	Submission 	- User requires additional feedback
 	case 9:
 		message = fmt.Sprintf("%s\n*%s* requires additional feedback", submissionLink, a.UserName)
@@ -119,62 +164,92 @@ func (c *Client) SlackFormatActivity(a Activity) (string, error) {
 		message = fmt.Sprintf("%s\n*%s* stopped requesting feedback", submissionLink, a.UserName)
 	//	20 	Program		- Status Change
 	case 20:
-		message = fmt.Sprintf("%s changed *program status* to `%s`", programLink, c.GetProgramState(a.Newstatusid))
+		t := a.Transition()
+		if t.From != "" {
+			message = fmt.Sprintf("%s changed *program status* `%s` → `%s`", programLink, t.From, t.To)
+		} else {
+			message = fmt.Sprintf("%s changed *program status* to `%s`", programLink, t.To)
+		}
 	//	22 	Program		- Update description
 	case 22:
-		descr := a.Description
-		if len(descr) > 500 {
-			descr = fmt.Sprintf("%s [...]", descr[:500])
+		diff := c.eventDiff(e)
+		if diff == nil || diff.Unavailable != "" {
+			message = fmt.Sprintf("%s changed *description* (%s)\n%s", programLink, c.DiffSlack(diff, 0), slackCodeBlock(a.Description, slackExcerptLength))
+			if len(a.Description) > slackExcerptLength {
+				full = a.Description
+			}
+			break
+		}
+		message = fmt.Sprintf("%s changed *description*\n%s", programLink, c.DiffSlack(diff, slackDiffLength))
+		if len(c.DiffSlack(diff, 0)) > slackDiffLength {
+			full = c.DiffString(diff)
 		}
-		message = fmt.Sprintf("%s changed description: \n```%s```", programLink, descr)
 	//	23 	Program		- Update bounties
 	case 23:
-		message = fmt.Sprintf("%s updated *bounties*", programLink)
+		diff := c.eventDiff(e)
+		message = fmt.Sprintf("%s updated *bounties*\n%s", programLink, c.DiffSlack(diff, slackDiffLength))
 	//	24 	Program		- Update scope
 	case 24:
-		diff := c.GetProgramContentDiff(a, "InScopes")
-		//		message = fmt.Sprintf("Program updated **in scope**\n```\n%s\n```", diff)
-		message = fmt.Sprintf("%s updated *scope*\n```\n%s\n```", programLink, diff)
+		diff := c.eventDiff(e)
+		message = fmt.Sprintf("%s updated *scope*\n%s", programLink, c.slackScopeDiff(e, diff))
 	//	25 	Program		- Update out of scope
 	case 25:
-		diff := c.GetProgramContentDiff(a, "OutScopes")
-		message = fmt.Sprintf("%s updated *out of scope*\n```\n%s\n```", programLink, diff)
+		diff := c.eventDiff(e)
+		message = fmt.Sprintf("%s updated *out of scope*\n%s", programLink, c.slackScopeDiff(e, diff))
 	//	26 	Program		- Update FAQ
 	case 26:
-		diff := c.GetProgramContentDiff(a, "Faqs")
-		message = fmt.Sprintf("%s updated *FAQ*\n```\n%s\n```", programLink, diff)
+		diff := c.eventDiff(e)
+		message = fmt.Sprintf("%s updated *FAQ*\n%s", programLink, c.DiffSlack(diff, slackDiffLength))
 	//	27 	Program		- Update domains
 	case 27:
-		diff := c.GetProgramDomainsDiff(a)
-		message = fmt.Sprintf("%s updated *domains*\n%s\n", programLink, diff)
+		diff := c.eventDiff(e)
+		message = fmt.Sprintf("%s updated *domains*\n%s", programLink, c.slackScopeDiff(e, diff))
 	//	28 	Program		- Update rules of engagement
 	case 28:
-		diff := c.GetProgramRulesDiff(a)
-		message = fmt.Sprintf("%s updated *rules of engagement*\n```\n%s\n```", programLink, diff)
+		diff := c.eventDiff(e)
+		message = fmt.Sprintf("%s updated *rules of engagement*\n%s", programLink, c.DiffSlack(diff, slackDiffLength))
 	//	29 	Program		- Update severity assessment
 	case 29:
-		diff := c.GetProgramContentDiff(a, "SeverityAssessments")
-		message = fmt.Sprintf("%s updated *severity assessment*\n```\n%s\n```", programLink, diff)
+		diff := c.eventDiff(e)
+		message = fmt.Sprintf("%s updated *severity assessment*\n%s", programLink, c.DiffSlack(diff, slackDiffLength))
 		//	47 	Program		- Program update published
 	case 47:
-		descr := a.Description
-		if len(descr) > 500 {
-			descr = fmt.Sprintf("%s [...]", descr[:500])
+		// Text of the activity, in full after the message when it is long
+		message = fmt.Sprintf("%s published a program update: *%s*\n%s", programLink, slackEscaper.Replace(a.Title), slackCodeBlock(a.Description, slackExcerptLength))
+		if len(a.Description) > slackExcerptLength {
+			full = a.Description
+		}
+
+	case ActivityNewProgram, ActivityProgramInvitation:
+		if e.Catalog == nil {
+			break
+		}
+		if d == ActivityProgramInvitation {
+			message = fmt.Sprintf(":envelope: *Invitation* to program %s", programLink)
+		} else {
+			message = fmt.Sprintf(":new: *New program* %s", programLink)
 		}
-		message = fmt.Sprintf("%s published a program update: *%s*\n```%s```", programLink, a.Title, descr)
+		message += "\n" + slackEscaper.Replace(c.catalogDetails(e.Catalog))
+
+	case ActivityProgramMetadata:
+		message = fmt.Sprintf(":pencil2: %s %s", programLink, slackEscaper.Replace(a.Title))
 
 	}
 	if message == "" {
-		message = fmt.Sprintf("Unknown message type: %d", a.Discriminator)
+		message = c.unknownActivityMessage(a, slackCodeBlock)
+	}
+
+	if details := c.submissionDetails(e.Submission); details != "" {
+		message += fmt.Sprintf("\n_%s_", details)
 	}
 
 	blockMsg := slackBlock{
 		Type: "section",
-		Text: slackBlockText{
+		Text: &slackBlockText{
 			Text: message,
 			Type: "mrkdwn",
 		},
-		Accessory: slackBlockAccessory{
+		Accessory: &slackBlockAccessory{
 			Type:    "image",
 			Url:     iconUrl,
 			AltText: a.Programname,
@@ -183,6 +258,17 @@ func (c *Client) SlackFormatActivity(a Activity) (string, error) {
 
 	block := make([]slackBlock, 0)
 	block = append(block, blockMsg)
+
+	// Time of the activity (not of delivery)
+	if a.CreatedAt > 0 {
+		block = append(block, slackBlock{
+			Type: "context",
+			Elements: []slackBlockText{{
+				Type: "mrkdwn",
+				Text: c.FormatTime(a.Time()),
+			}},
+		})
+	}
 	slackMsg := slackMessage{
 		Text:   message,
 		Mrkdwn: true,
@@ -192,9 +278,49 @@ func (c *Client) SlackFormatActivity(a Activity) (string, error) {
 	jsonMsg, err := json.Marshal(slackMsg)
 
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	return string(jsonMsg), nil
+	var followUps []string
+	if full != "" {
+		for _, m := range slackFollowUps(full) {
+			data, err := json.Marshal(m)
+			if err != nil {
+				return "", nil, err
+			}
+			followUps = append(followUps, string(data))
+		}
+	}
+
+	return string(jsonMsg), followUps, nil
+
+}
+
+// slackCodeBlock returns text shortened to n bytes as a code block
+func slackCodeBlock(text string, n int) string {
+	return "```" + slackEscaper.Replace(strings.Replace(truncate(text, n), "```", "'''", -1)) + "```"
+}
+
+// slackFollowUps returns messages with the whole text split into code blocks
+func slackFollowUps(text string) []slackMessage {
+	parts := splitText(text, slackFollowUpLength)
+
+	messages := make([]slackMessage, 0, len(parts))
+	for i, part := range parts {
+		quoted := fmt.Sprintf("_Full text (%d/%d):_\n```%s```", i+1, len(parts),
+			slackEscaper.Replace(strings.Replace(part, "```", "'''", -1)))
+		messages = append(messages, slackMessage{
+			Text:   quoted,
+			Mrkdwn: true,
+			Blocks: []slackBlock{{Type: "section", Text: &slackBlockText{Type: "mrkdwn", Text: quoted}}},
+		})
+	}
+	return messages
+}
 
+// slackScopeDiff renders the diff of a scope event followed by scope
+// conflicts, together at most slackDiffLength bytes
+func (c *Client) slackScopeDiff(e ActivityEvent, diff *ProgramDiff) string {
+	conflicts := c.eventConflicts(e).render(slackDiffStyle, slackConflictsLength)
+	return c.DiffSlack(diff, slackDiffLength-len(conflicts)) + conflicts
 }
@@ -0,0 +1,274 @@
+package intitools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+)
+
+// snapshotVersionFormat names snapshot files so that they sort by fetch time
+const snapshotVersionFormat = "20060102T150405Z"
+
+// SnapshotStore is a local store of program versions. Every fetched program
+// is kept as a JSON file in <dir>/<company>/<handle>/<version>.json where
+// version is the fetch time. A program is stored only if it differs from the
+// latest snapshot.
+type SnapshotStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+type Snapshot struct {
+	Version   string    `json:"version"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	Program   Program   `json:"program"`
+}
+
+// FieldChange is a change of a single program field between two snapshots
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// OpenSnapshotStore opens (and creates if needed) the snapshot store in directory dir
+func OpenSnapshotStore(dir string) (*SnapshotStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &SnapshotStore{dir: dir}, nil
+}
+
+func (s *SnapshotStore) programDir(company string, handle string) string {
+	return filepath.Join(s.dir, filepath.Base(company), filepath.Base(handle))
+}
+
+// Save stores the program unless it equals the latest snapshot. It reports
+// whether a new snapshot was written.
+func (s *SnapshotStore) Save(p *Program) (bool, error) {
+	if p.CompanyHandle == "" || p.Handle == "" {
+		return false, fmt.Errorf("program without handle")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latest, err := s.latest(p.CompanyHandle, p.Handle)
+	if err != nil {
+		return false, err
+	}
+	if latest != nil {
+		old, _ := json.Marshal(latest.Program)
+		cur, _ := json.Marshal(p)
+		if bytes.Equal(old, cur) {
+			return false, nil
+		}
+	}
+
+	now := time.Now().UTC()
+	snap := Snapshot{
+		Version:   now.Format(snapshotVersionFormat),
+		FetchedAt: now,
+		Program:   *p,
+	}
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return false, err
+	}
+
+	dir := s.programDir(p.CompanyHandle, p.Handle)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return false, err
+	}
+
+	// Write to a temporary file first so that readers never see partial snapshots
+	path := filepath.Join(dir, snap.Version+".json")
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return false, err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Versions returns snapshot versions of the program from the oldest one
+func (s *SnapshotStore) Versions(company string, handle string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.programDir(company, handle), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]string, 0, len(files))
+	for _, f := range files {
+		versions = append(versions, strings.TrimSuffix(filepath.Base(f), ".json"))
+	}
+	sort.Strings(versions)
+
+	return versions, nil
+}
+
+// Load returns the snapshot of the program with given version
+func (s *SnapshotStore) Load(company string, handle string, version string) (*Snapshot, error) {
+	data, err := os.ReadFile(filepath.Join(s.programDir(company, handle), filepath.Base(version)+".json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("snapshot %s/%s %s: %w", company, handle, version, err)
+	}
+
+	return &snap, nil
+}
+
+// Latest returns the newest snapshot of the program or nil if there is none
+func (s *SnapshotStore) Latest(company string, handle string) (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.latest(company, handle)
+}
+
+func (s *SnapshotStore) latest(company string, handle string) (*Snapshot, error) {
+	versions, err := s.Versions(company, handle)
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	return s.Load(company, handle, versions[len(versions)-1])
+}
+
+// Programs returns "company/handle" of all programs with snapshots
+func (s *SnapshotStore) Programs() ([]string, error) {
+	dirs, err := filepath.Glob(filepath.Join(s.dir, "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var programs []string
+	for _, d := range dirs {
+		if fi, err := os.Stat(d); err != nil || !fi.IsDir() {
+			continue
+		}
+		programs = append(programs, filepath.Base(filepath.Dir(d))+"/"+filepath.Base(d))
+	}
+	sort.Strings(programs)
+
+	return programs, nil
+}
+
+// confidentialityLevels maps program confidentiality levels to their names
+var confidentialityLevels = []string{
+	"Unknown",
+	"Invite only",
+	"Application",
+	"Registered",
+	"Public",
+}
+
+// ConfidentialityLevelName returns the name of confidentiality level
+func ConfidentialityLevelName(level int) string {
+	return lookupName(confidentialityLevels, level)
+}
+
+// DiffPrograms returns changes between two versions of a program. Versioned
+// content (scopes, FAQ, rules, domains) is compared by its latest version.
+func DiffPrograms(old *Program, new *Program) []FieldChange {
+	var changes []FieldChange
+
+	add := func(field string, o string, n string) {
+		if o != n {
+			changes = append(changes, FieldChange{Field: field, Old: o, New: n})
+		}
+	}
+
+	add("Name", old.Name, new.Name)
+	add("Company", old.CompanyName, new.CompanyName)
+	add("Status", programState(old.Status), programState(new.Status))
+	add("ConfidentialityLevel", ConfidentialityLevelName(old.ConfidentialityLevel), ConfidentialityLevelName(new.ConfidentialityLevel))
+	add("MinBounty", old.MinBounty, new.MinBounty)
+	add("MaxBounty", old.MaxBounty, new.MaxBounty)
+	add("IdentityCheckedRequired", fmt.Sprint(old.IdentityCheckedRequired), fmt.Sprint(new.IdentityCheckedRequired))
+	add("AwardRep", fmt.Sprint(old.AwardRep), fmt.Sprint(new.AwardRep))
+	add("SkipTriage", fmt.Sprint(old.SkipTriage), fmt.Sprint(new.SkipTriage))
+	add("Description", old.Description, new.Description)
+	add("InScopes", latestContent(old.InScopes), latestContent(new.InScopes))
+	add("OutScopes", latestContent(old.OutScopes), latestContent(new.OutScopes))
+	add("Faqs", latestContent(old.Faqs), latestContent(new.Faqs))
+	add("SeverityAssessments", latestContent(old.SeverityAssessments), latestContent(new.SeverityAssessments))
+	add("RulesOfEngagement", latestRules(old.RulesOfEngagement), latestRules(new.RulesOfEngagement))
+	add("Domains", domainList(latestDomains(old.Domains)), domainList(latestDomains(new.Domains)))
+	changes = append(changes, bountyChanges(latestBounties(old.BountyTables), latestBounties(new.BountyTables))...)
+
+	return changes
+}
+
+// latestContent returns the newest version of a text section (versions
+// are not necessarily ordered, see matchHistory)
+func latestContent(changes []ProgramChanges) string {
+	created := make([]int64, len(changes))
+	for i, chg := range changes {
+		created[i] = chg.CreatedAt
+	}
+	if i := newestVersion(created); i >= 0 {
+		return changes[i].Content.Content
+	}
+	return ""
+}
+
+func latestRules(changes []ProgramRulesChanges) string {
+	created := make([]int64, len(changes))
+	for i, chg := range changes {
+		created[i] = chg.CreatedAt
+	}
+	if i := newestVersion(created); i >= 0 {
+		return changes[i].Content.Content.Description
+	}
+	return ""
+}
+
+func latestDomains(changes []ProgramDomains) []ProgramDomainsContent {
+	created := make([]int64, len(changes))
+	for i, chg := range changes {
+		created[i] = chg.CreatedAt
+	}
+	if i := newestVersion(created); i >= 0 {
+		return changes[i].Content
+	}
+	return nil
+}
+
+// domainList lists domains one per line, sorted to get stable diffs
+func domainList(domains []ProgramDomainsContent) string {
+	lines := make([]string, 0, len(domains))
+	for _, d := range domains {
+		lines = append(lines, fmt.Sprintf("%s (%s, %s)", d.Endpoint, lookupName(endpointTypes, d.Type), lookupName(endpointTiers, d.BountyTierId)))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// Unified returns the change as a unified diff for multi-line values or as
+// "old → new" for single line ones
+func (fc FieldChange) Unified() string {
+	if !strings.Contains(fc.Old, "\n") && !strings.Contains(fc.New, "\n") && len(fc.Old) < 100 && len(fc.New) < 100 {
+		return fmt.Sprintf("%s: %s → %s", fc.Field, fc.Old, fc.New)
+	}
+
+	o, n := fc.Old+"\n", fc.New+"\n"
+	edits := myers.ComputeEdits(span.URIFromPath(fc.Field), o, n)
+	return fmt.Sprintf("%s:\n%s", fc.Field, gotextdiff.ToUnified("old", "new", o, edits))
+}
@@ -0,0 +1,355 @@
+package intitools
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type Submission struct {
+	Code        string             `json:"code"`
+	Title       string             `json:"title"`
+	ProgramId   string             `json:"programId"`
+	State       ResponseState      `json:"state"`
+	SeverityId  int                `json:"severityId"`
+	TotalPayout ResponsePayout     `json:"totalPayout"`
+	Type        SubmissionType     `json:"type"`
+	Endpoint    string             `json:"endpointVulnerableComponent"`
+	Triager     ResponseUser       `json:"triager"`
+	Payouts     []SubmissionPayout `json:"payouts"`
+}
+
+type SubmissionType struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+type SubmissionPayout struct {
+	Amount    ResponsePayout `json:"amount"`
+	Type      int            `json:"type"`
+	CreatedAt int64          `json:"createdAt"`
+}
+
+type SubmissionMessage struct {
+	Id          string                 `json:"id"`
+	Message     string                 `json:"message"`
+	CreatedAt   int64                  `json:"createdAt"`
+	User        ResponseUser           `json:"user"`
+	Attachments []SubmissionAttachment `json:"attachments"`
+}
+
+type SubmissionAttachment struct {
+	Id       string `json:"id"`
+	FileName string `json:"fileName"`
+}
+
+// DuplicateSubmission is the original report of a submission closed as duplicate
+type DuplicateSubmission struct {
+	Code       string
+	ProgramId  string
+	Visible    bool // Details of the original report are visible to us
+	State      ResponseState
+	SeverityId int
+}
+
+// GetSubmission fetches details of a single submission
+func (c *Client) GetSubmission(ctx context.Context, programId string, code string) (*Submission, error) {
+
+	apiURL := fmt.Sprintf("%s/core/researcher/submissions/%s/%s", c.ApiURL,
+		url.PathEscape(programId), url.PathEscape(code))
+
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+
+	res := Submission{}
+
+	if err := c.sendRequest(req, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// GetSubmissionMessages fetches the message thread of a submission
+func (c *Client) GetSubmissionMessages(ctx context.Context, programId string, code string) ([]SubmissionMessage, error) {
+
+	apiURL := fmt.Sprintf("%s/core/researcher/submissions/%s/%s/messages", c.ApiURL,
+		url.PathEscape(programId), url.PathEscape(code))
+
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+
+	res := []SubmissionMessage{}
+
+	if err := c.sendRequest(req, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// findActivityMessage returns the message announced by a new message activity:
+// the message of the same user created closest to the activity
+func findActivityMessage(messages []SubmissionMessage, a Activity) *SubmissionMessage {
+	var found *SubmissionMessage
+	var bestDelta int64 = -1
+
+	activityCreated := toMillis(a.CreatedAt)
+	for i := range messages {
+		m := &messages[i]
+		if a.User.Userid != "" && m.User.Userid != a.User.Userid {
+			continue
+		}
+
+		delta := toMillis(m.CreatedAt) - activityCreated
+		if delta < 0 {
+			delta = -delta
+		}
+		if bestDelta < 0 || delta < bestDelta {
+			found, bestDelta = m, delta
+		}
+	}
+
+	return found
+}
+
+// toMillis converts timestamp in seconds or milliseconds to milliseconds
+func toMillis(t int64) int64 {
+	if t < 1e12 {
+		return t * 1000
+	}
+	return t
+}
+
+// IsSubmissionActivity reports whether the activity relates to a submission
+func IsSubmissionActivity(a Activity) bool {
+	return a.Discriminator >= 1 && a.Discriminator <= 11 && a.Submissioncode != ""
+}
+
+// EnrichError lists details Enrich could not add. The event keeps all other
+// details.
+type EnrichError []error
+
+func (e EnrichError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Enrich adds submission details to submission activities and diffs to
+// program content updates. A failed lookup does not stop the others, the
+// failures are returned together as EnrichError.
+func (c *Client) Enrich(ctx context.Context, e *ActivityEvent) error {
+	var errs EnrichError
+
+	if e.Diff == nil {
+		d, err := c.ProgramActivityDiff(e.Activity)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot fetch program diff: %w", err))
+		}
+		e.Diff = d
+	}
+
+	if IsScopeActivity(e.Activity.Discriminator) && e.Conflicts == nil {
+		conflicts, err := c.ScopeConflicts(e.Activity)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot check scope conflicts: %w", err))
+		}
+		e.Conflicts = conflicts
+	}
+
+	// Our own messages are not sent at all
+	if IsSubmissionActivity(e.Activity) && !(e.Activity.Discriminator == 1 && e.Activity.User.Role == "RESEARCHER") {
+		c.enrichSubmission(ctx, e, &errs)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// enrichSubmission adds the submission, the original report of duplicates
+// and the message of new message activities
+func (c *Client) enrichSubmission(ctx context.Context, e *ActivityEvent, errs *EnrichError) {
+	s, err := c.GetSubmission(ctx, e.Activity.Programid, e.Activity.Submissioncode)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("cannot fetch submission %s: %w", e.Activity.Submissioncode, err))
+	} else {
+		e.Submission = s
+	}
+
+	// The reference is usually in the activity, the submission is a fallback
+	if dup := duplicateReference(e.Activity, s); dup != "" {
+		e.Duplicate = c.ResolveDuplicate(ctx, e.Activity.Programid, dup)
+	}
+
+	if e.Activity.Discriminator == 1 {
+		messages, err := c.GetSubmissionMessages(ctx, e.Activity.Programid, e.Activity.Submissioncode)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("cannot fetch messages of submission %s: %w", e.Activity.Submissioncode, err))
+			return
+		}
+		e.Message = findActivityMessage(messages, e.Activity)
+	}
+}
+
+// duplicateReference returns reference of the original report if the
+// activity closed submission as duplicate
+func duplicateReference(a Activity, s *Submission) string {
+	if a.Discriminator != 2 || a.Newstate.Status != 4 || a.Newstate.Closereason != 2 {
+		return ""
+	}
+	if a.Newstate.Duplicatesubmission != "" {
+		return a.Newstate.Duplicatesubmission
+	}
+	if s != nil {
+		return s.State.Duplicatesubmission
+	}
+	return ""
+}
+
+// ResolveDuplicate fetches the original report of a duplicate. Original
+// reports of other researchers are usually not visible, in that case only
+// the reference is returned.
+func (c *Client) ResolveDuplicate(ctx context.Context, programId string, ref string) *DuplicateSubmission {
+	dup := &DuplicateSubmission{Code: ref, ProgramId: programId}
+
+	s, err := c.GetSubmission(ctx, programId, ref)
+	if err != nil {
+		return dup
+	}
+
+	if s.Code != "" {
+		dup.Code = s.Code
+	}
+	if s.ProgramId != "" {
+		dup.ProgramId = s.ProgramId
+	}
+	dup.Visible = true
+	dup.State = s.State
+	dup.SeverityId = s.SeverityId
+
+	return dup
+}
+
+// submissionURL returns link to the submission in the researcher app
+func (c *Client) submissionURL(programId string, code string) string {
+	return fmt.Sprintf("%s/researcher/submissions/%s/%s", c.AppURL, url.PathEscape(programId), url.PathEscape(code))
+}
+
+// programURL returns link to the program details in the researcher app
+func (c *Client) programURL(companyHandle string, programHandle string) string {
+	return fmt.Sprintf("%s/researcher/programs/%s/%s/detail", c.AppURL, url.PathEscape(companyHandle), url.PathEscape(programHandle))
+}
+
+// duplicateDetails returns state and severity of the original report
+func (c *Client) duplicateDetails(d *DuplicateSubmission) string {
+	if !d.Visible {
+		return ""
+	}
+
+	var details []string
+	if d.State.Status > 0 {
+		state := c.GetSubmissionState(d.State.Status)
+		if d.State.Status == 4 {
+			state += " as " + c.GetClosedState(d.State.Closereason)
+		}
+		details = append(details, state)
+	}
+	if d.SeverityId > 0 {
+		details = append(details, c.GetSeverity(d.SeverityId))
+	}
+
+	if len(details) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(details, ", ") + ")"
+}
+
+// messageExcerpt returns sanitized and shortened message text and the list of attachments
+func messageExcerpt(m *SubmissionMessage) (string, string) {
+	if m == nil {
+		return "", ""
+	}
+
+	var names []string
+	for _, at := range m.Attachments {
+		if at.FileName != "" {
+			names = append(names, sanitizeText(at.FileName, 100))
+		}
+	}
+
+	return sanitizeText(m.Message, messageExcerptLength), strings.Join(names, ", ")
+}
+
+// submissionDetails returns a single line summary of submission details
+func (c *Client) submissionDetails(s *Submission) string {
+	if s == nil {
+		return ""
+	}
+
+	var details []string
+
+	if s.SeverityId > 0 {
+		details = append(details, c.GetSeverity(s.SeverityId))
+	}
+	if s.State.Status > 0 {
+		state := c.GetSubmissionState(s.State.Status)
+		if s.State.Status == 4 {
+			state += " as " + c.GetClosedState(s.State.Closereason)
+		}
+		details = append(details, state)
+	}
+	if s.Type.Name != "" {
+		details = append(details, s.Type.Name)
+	}
+	if s.TotalPayout.Value > 0 {
+		details = append(details, "bounty "+FormatMoney(s.TotalPayout.Currency, s.TotalPayout.Value))
+	}
+	if s.Endpoint != "" {
+		details = append(details, truncate(s.Endpoint, 100))
+	}
+	if s.Triager.Username != "" {
+		details = append(details, "triager "+s.Triager.Username)
+	}
+
+	return strings.Join(details, " | ")
+}
+
+// previousSeverity returns severity set by the most recent severity change of
+// the same submission older than activities[idx] or 0 if it is unknown.
+// Feed is ordered from the newest to the oldest activity.
+func previousSeverity(activities []Activity, idx int) int {
+	a := activities[idx]
+	for _, older := range activities[idx+1:] {
+		if older.Discriminator == 3 && older.Submissioncode == a.Submissioncode && older.Programid == a.Programid {
+			return older.Newseverityid
+		}
+	}
+	return 0
+}
+
+// payoutAmount returns formatted payout amount including submission total if known
+func (c *Client) payoutAmount(e ActivityEvent) string {
+	p := e.Activity.NewPayoutAmount
+	amount := FormatMoney(p.Currency, p.Value)
+
+	if s := e.Submission; s != nil && s.TotalPayout.Value > 0 && s.TotalPayout.Value != p.Value {
+		amount += fmt.Sprintf(" (total %s)", FormatMoney(s.TotalPayout.Currency, s.TotalPayout.Value))
+	}
+
+	return amount
+}
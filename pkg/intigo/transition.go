@@ -0,0 +1,86 @@
+package intitools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Transition is a status change of a submission or program
+type Transition struct {
+	FromId    int    `json:"fromId"`
+	ToId      int    `json:"toId"`
+	ToReason  int    `json:"toReason,omitempty"` // Close reason if submission was closed
+	From      string `json:"from"`               // Empty if previous status is unknown
+	To        string `json:"to"`                 // Includes close reason, e.g. "Closed as Duplicate"
+	FromState string `json:"fromState"`          // State without close reason
+	ToState   string `json:"toState"`
+}
+
+// Transition returns status transition of submission (2) and program (20)
+// status changes or nil for other activities
+func (a Activity) Transition() *Transition {
+	switch a.Discriminator {
+	case 2:
+		t := &Transition{
+			FromId:  a.Oldstatusid,
+			ToId:    a.Newstate.Status,
+			ToState: lookupName(submissionStates, a.Newstate.Status),
+		}
+		t.To = t.ToState
+		if a.Newstate.Status == 4 {
+			t.ToReason = a.Newstate.Closereason
+			t.To += " as " + lookupName(closedStates, a.Newstate.Closereason)
+		}
+		if a.Oldstatusid > 0 {
+			t.FromState = lookupName(submissionStates, a.Oldstatusid)
+			t.From = t.FromState
+		}
+		return t
+
+	case 20:
+		t := &Transition{
+			FromId:  a.Oldstatusid,
+			ToId:    a.Newstatusid,
+			ToState: programState(a.Newstatusid),
+		}
+		t.To = t.ToState
+		if a.Oldstatusid > 0 {
+			t.FromState = programState(a.Oldstatusid)
+			t.From = t.FromState
+		}
+		return t
+	}
+
+	return nil
+}
+
+func programState(id int) string {
+	if state, ok := programStates[id]; ok {
+		return state
+	}
+	return fmt.Sprintf("Unknown: %d", id)
+}
+
+// String returns e.g. "Triage → Closed as Duplicate" or "→ Accepted" if the
+// previous status is unknown
+func (t *Transition) String() string {
+	if t.From == "" {
+		return "→ " + t.To
+	}
+	return t.From + " → " + t.To
+}
+
+// matchStates reports whether full status (with close reason) or bare state
+// is in the list (case-insensitive). Empty status never matches.
+func matchStates(list []string, full string, state string) bool {
+	if full == "" {
+		return false
+	}
+	for _, v := range list {
+		v = strings.TrimSpace(v)
+		if strings.EqualFold(v, full) || strings.EqualFold(v, state) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,15 @@
 package intitools
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"golang.org/x/net/html"
 )
@@ -34,3 +42,146 @@ func (c *Client) getElementValue(name string, n *html.Node) (string, error) {
 	return "", fmt.Errorf("Cannot find value of element %s", name)
 
 }
+
+// truncate shortens s to at most n bytes without splitting multi-byte characters
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + " [...]"
+}
+
+// splitText splits s into parts of at most n bytes, at line ends where
+// possible and never inside multi-byte characters
+func splitText(s string, n int) []string {
+	var parts []string
+	for len(s) > n {
+		cut := strings.LastIndex(s[:n], "\n") + 1
+		if cut <= n/2 {
+			cut = n
+			for cut > 0 && !utf8.RuneStart(s[cut]) {
+				cut--
+			}
+		}
+		parts = append(parts, s[:cut])
+		s = s[cut:]
+	}
+	if s != "" {
+		parts = append(parts, s)
+	}
+	return parts
+}
+
+// appendJSONLine appends v encoded as a single JSON line to the file at path
+func appendJSONLine(path string, v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+var currencySymbols = map[string]string{
+	"EUR": "€",
+	"USD": "$",
+	"GBP": "£",
+}
+
+// FormatMoney formats an amount with currency symbol and thousands separators,
+// e.g. "€5,000" or "€120.50"
+func FormatMoney(currency string, value float64) string {
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	cents := int64(math.Round(value * 100))
+	whole := fmt.Sprintf("%d", cents/100)
+
+	var b strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	if cents%100 != 0 {
+		fmt.Fprintf(&b, ".%02d", cents%100)
+	}
+
+	if symbol, ok := currencySymbols[strings.ToUpper(currency)]; ok {
+		return sign + symbol + b.String()
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %s%s", currency, sign, b.String()))
+}
+
+const messageExcerptLength = 500
+
+var (
+	htmlTagRe    = regexp.MustCompile(`<[^>]*>`)
+	blankLinesRe = regexp.MustCompile(`\n{3,}`)
+	htmlEntities = strings.NewReplacer("&nbsp;", " ", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&#39;", "'", "&amp;", "&")
+)
+
+// sanitizeText strips HTML tags and control characters from user provided
+// text, collapses blank lines and limits it to n bytes
+func sanitizeText(s string, n int) string {
+	s = htmlTagRe.ReplaceAllString(s, "")
+	s = htmlEntities.Replace(s)
+	s = strings.Replace(s, "\r\n", "\n", -1)
+
+	s = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, s)
+
+	s = blankLinesRe.ReplaceAllString(strings.TrimSpace(s), "\n\n")
+
+	return truncate(s, n)
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackQuote escapes text for Slack mrkdwn and formats it as a quote
+func slackQuote(s string) string {
+	return "> " + strings.Replace(slackEscaper.Replace(s), "\n", "\n> ", -1)
+}
+
+// discordQuote escapes mentions and formats text as a Discord quote
+func discordQuote(s string) string {
+	s = strings.Replace(s, "@", "@\u200b", -1)
+	return "> " + strings.Replace(s, "\n", "\n> ", -1)
+}
+
+// FormatTime formats t in the configured display time zone and format
+func (c *Client) FormatTime(t time.Time) string {
+	layout := c.TimeFormat
+	if layout == "" {
+		layout = DefaultTimeFormat
+	}
+	return t.In(c.location()).Format(layout)
+}
+
+func (c *Client) location() *time.Location {
+	if c.Location == nil {
+		return time.Local
+	}
+	return c.Location
+}
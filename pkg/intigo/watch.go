@@ -0,0 +1,205 @@
+package intitools
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	defaultWatchInterval = 60 * time.Second
+	defaultWatchBuffer   = 16
+	watchDedupeSize      = 1000
+)
+
+// ActivityEvent is a single activity (or polling error) emitted by Watch
+type ActivityEvent struct {
+	Activity   Activity
+	Known      bool      // Activity type is supported by the formatters
+	ReceivedAt time.Time // When the activity was fetched from the feed
+	Err        error     // Polling error. Activity is empty when set.
+
+	PreviousSeverity int // Severity before a severity change (0 if unknown)
+
+	// Enrichment (set by WatchOptions.Enrich or pipeline enrichers)
+	Submission *Submission          // Details of the related submission
+	Diff       *ProgramDiff         // Change of program content made by the activity
+	Message    *SubmissionMessage   // New submission message
+	Duplicate  *DuplicateSubmission // Original report of a submission closed as duplicate
+	Conflicts  ScopeConflicts       // Scope conflicts of the program after a scope update
+
+	Catalog *CatalogProgram // Program announced by a synthetic catalog event
+}
+
+type WatchOptions struct {
+	Interval  time.Duration // Base polling interval (default 60s)
+	Scheduler *Scheduler    // Polling schedule (default NewScheduler(Interval))
+	SendLast  int           // Number of already seen activities emitted on the first poll
+	Buffer    int           // Size of the event channel buffer (default 16)
+	Enrich    bool          // Fetch submission details (not needed if events go through a Pipeline with Client as enricher)
+}
+
+// watcher keeps the state of a single Watch call
+type watcher struct {
+	c        *Client
+	opts     WatchOptions
+	out      chan ActivityEvent
+	sendlast int
+	seen     map[string]bool
+	seenList []string
+}
+
+// Watch polls the activity feed and emits new activities on the returned channel.
+// Polling errors are reported as events with Err set. The channel is closed
+// when ctx is cancelled.
+func (c *Client) Watch(ctx context.Context, opts WatchOptions) (<-chan ActivityEvent, error) {
+	if opts.Interval < 0 || opts.SendLast < 0 || opts.Buffer < 0 {
+		return nil, fmt.Errorf("invalid watch options")
+	}
+	if opts.Interval == 0 {
+		opts.Interval = defaultWatchInterval
+	}
+	if opts.Scheduler == nil {
+		opts.Scheduler = NewScheduler(opts.Interval)
+	}
+	if opts.Buffer == 0 {
+		opts.Buffer = defaultWatchBuffer
+	}
+	if c.HttpCtx == nil {
+		c.HttpCtx = ctx
+	}
+
+	w := &watcher{
+		c:        c,
+		opts:     opts,
+		out:      make(chan ActivityEvent, opts.Buffer),
+		sendlast: opts.SendLast,
+		seen:     make(map[string]bool),
+	}
+
+	go w.run(ctx)
+
+	return w.out, nil
+}
+
+func (w *watcher) run(ctx context.Context) {
+	defer close(w.out)
+
+	// First poll is done immediately
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		found, err := w.poll(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && !w.emit(ctx, ActivityEvent{Err: err}) {
+			return
+		}
+
+		timer.Reset(w.opts.Scheduler.Next(w.opts.Scheduler.now(), found, err))
+	}
+}
+
+// poll runs a single poll and returns the number of emitted activities
+func (w *watcher) poll(ctx context.Context) (int, error) {
+	c := w.c
+
+	// Cursor is taken before checking so activities created during the poll
+	// are picked up next time (duplicates are dropped below).
+	cursor := time.Now().UTC().Unix()
+
+	if err := c.Authenticate(); err != nil {
+		return 0, fmt.Errorf("Authentication error: %w", err)
+	}
+
+	numActivities, err := c.CheckActivity()
+	if err != nil {
+		return 0, fmt.Errorf("CheckActivity error: %w", err)
+	}
+
+	// Use sendlast for first iteration and reset for all other
+	numActivities += w.sendlast
+	w.sendlast = 0
+
+	if numActivities == 0 {
+		return 0, nil
+	}
+
+	res, err := c.GetActivities(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("GetActivities error: %w", err)
+	}
+
+	found := 0
+	receivedAt := time.Now()
+	for idx, activity := range res.Activities {
+		if idx > numActivities-1 {
+			break
+		}
+
+		key := activityKey(activity)
+		if w.seen[key] {
+			continue
+		}
+		w.remember(key)
+
+		known := IsKnownActivity(activity.Discriminator)
+		if !known {
+			if err := c.LogUnknownActivity(activity); err != nil {
+				log.Printf("Unknown activity log error: %s\n", err)
+			}
+		}
+
+		e := ActivityEvent{Activity: activity, Known: known, ReceivedAt: receivedAt}
+		if activity.Discriminator == 3 {
+			e.PreviousSeverity = previousSeverity(res.Activities, idx)
+		}
+		if w.opts.Enrich {
+			if err := c.Enrich(ctx, &e); err != nil {
+				log.Printf("Enrich error: %s\n", err)
+			}
+		}
+
+		if !w.emit(ctx, e) {
+			return found, ctx.Err()
+		}
+		found++
+	}
+
+	c.LastViewed = cursor
+
+	return found, nil
+}
+
+func (w *watcher) emit(ctx context.Context, e ActivityEvent) bool {
+	select {
+	case w.out <- e:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// remember adds key to the dedupe set dropping the oldest entries
+func (w *watcher) remember(key string) {
+	w.seen[key] = true
+	w.seenList = append(w.seenList, key)
+	if len(w.seenList) > watchDedupeSize {
+		delete(w.seen, w.seenList[0])
+		w.seenList = w.seenList[1:]
+	}
+}
+
+// activityKey identifies an activity in the feed
+func activityKey(a Activity) string {
+	return fmt.Sprintf("%d/%d/%s/%s/%s", a.CreatedAt, a.Discriminator, a.Programid, a.Submissioncode, a.User.Userid)
+}